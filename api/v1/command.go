@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"errors"
+	"strings"
+)
+
+// ShellCommand the command used to run startCmd when spec.shell is true
+var ShellCommand = []string{"/bin/sh", "-c"}
+
+var (
+	ErrUnterminatedQuote = errors.New("unterminated quoted string")
+	ErrTrailingEscape    = errors.New("command ends with an unfinished escape character")
+	ErrEmptyCommand      = errors.New("command has no words")
+)
+
+// SplitCommand splits a command line into words the way a POSIX shell does,
+// honoring single quotes, double quotes and backslash escapes. Variables,
+// globs and operators are not interpreted, use spec.shell for them.
+func SplitCommand(cmd string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		escaped bool
+		quote   rune
+	)
+
+	for _, c := range cmd {
+		if escaped {
+			// Inside double quotes backslash only escapes a few characters
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", c) {
+				word.WriteRune('\\')
+			}
+			// A backslash-newline is a line continuation
+			if c != '\n' {
+				word.WriteRune(c)
+				inWord = true
+			}
+			escaped = false
+			continue
+		}
+
+		switch quote {
+		case '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+			continue
+		case '"':
+			switch c {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				word.WriteRune(c)
+			}
+			continue
+		}
+
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case '\\':
+			escaped = true
+		case '\'', '"':
+			quote = c
+			inWord = true
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+
+	if escaped {
+		return nil, ErrTrailingEscape
+	}
+	if quote != 0 {
+		return nil, ErrUnterminatedQuote
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// ContainerCommand returns the container command and args built from startCmd, args and shell.
+// A nil command means the image's ENTRYPOINT is kept.
+func (s *SingleDeploymentSpec) ContainerCommand(name string) ([]string, []string, error) {
+	if s.StartCmd == "" {
+		return nil, s.Args, nil
+	}
+
+	if s.Shell {
+		command := append(append([]string{}, ShellCommand...), s.StartCmd)
+		if len(s.Args) == 0 {
+			return command, nil, nil
+		}
+		// The first parameter after the script is $0, use the instance name for it
+		return command, append([]string{name}, s.Args...), nil
+	}
+
+	command, err := SplitCommand(s.StartCmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(command) == 0 {
+		return nil, nil, ErrEmptyCommand
+	}
+
+	return command, s.Args, nil
+}
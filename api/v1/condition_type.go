@@ -12,20 +12,69 @@ const (
 )
 
 const (
-	ConditionTypeDeployment = "deployment"
-	ConditionTypeService    = "service"
-	ConditionTypeIngress    = "ingress"
+	ConditionTypeDeployment       = "deployment"
+	ConditionTypeBuild            = "build"
+	ConditionTypeService          = "service"
+	ConditionTypeIngress          = "ingress"
+	ConditionTypeRoute            = "route"
+	ConditionTypeCertificate      = "certificate"
+	ConditionTypeStorage          = "storage"
+	ConditionTypeConfig           = "config"
+	ConditionTypeAutoscaling      = "autoscaling"
+	ConditionTypeDisruptionBudget = "disruptionBudget"
+	ConditionTypeServiceAccount   = "serviceAccount"
+	ConditionTypeRegistryAuth     = "registryAuth"
+	ConditionTypeRollout          = "rollout"
+	ConditionTypeRevision         = "revision"
+	ConditionTypeSchedule         = "schedule"
 )
 
 const (
-	ConditionReasonDeploymentAvailable   = "NewDeploymentAvailable"
-	ConditionReasonDeploymentUnavailable = "NewDeploymentUnavailable"
+	ConditionReasonDeploymentAvailable       = "NewDeploymentAvailable"
+	ConditionReasonDeploymentUnavailable     = "NewDeploymentUnavailable"
+	ConditionReasonDeploymentImagePullFailed = "NewDeploymentImagePullFailed"
+
+	ConditionReasonBuildAvailable   = "NewBuildAvailable"
+	ConditionReasonBuildUnavailable = "NewBuildUnavailable"
 
 	ConditionReasonServiceAvailable   = "NewServiceAvailable"
 	ConditionReasonServiceUnavailable = "NewServiceUnavailable"
 
 	ConditionReasonIngressAvailable   = "NewIngressAvailable"
 	ConditionReasonIngressUnavailable = "NewIngressUnavailable"
+
+	ConditionReasonRouteAvailable   = "NewRouteAvailable"
+	ConditionReasonRouteUnavailable = "NewRouteUnavailable"
+
+	ConditionReasonCertificateAvailable   = "NewCertificateAvailable"
+	ConditionReasonCertificateUnavailable = "NewCertificateUnavailable"
+
+	ConditionReasonStorageAvailable   = "NewStorageAvailable"
+	ConditionReasonStorageUnavailable = "NewStorageUnavailable"
+
+	ConditionReasonConfigAvailable   = "NewConfigAvailable"
+	ConditionReasonConfigUnavailable = "NewConfigUnavailable"
+
+	ConditionReasonAutoscalingAvailable   = "NewAutoscalingAvailable"
+	ConditionReasonAutoscalingUnavailable = "NewAutoscalingUnavailable"
+
+	ConditionReasonDisruptionBudgetAvailable   = "NewDisruptionBudgetAvailable"
+	ConditionReasonDisruptionBudgetUnavailable = "NewDisruptionBudgetUnavailable"
+
+	ConditionReasonServiceAccountAvailable   = "NewServiceAccountAvailable"
+	ConditionReasonServiceAccountUnavailable = "NewServiceAccountUnavailable"
+
+	ConditionReasonRegistryAuthAvailable   = "NewRegistryAuthAvailable"
+	ConditionReasonRegistryAuthUnavailable = "NewRegistryAuthUnavailable"
+
+	ConditionReasonRolloutAvailable   = "NewRolloutAvailable"
+	ConditionReasonRolloutUnavailable = "NewRolloutUnavailable"
+
+	ConditionReasonRevisionAvailable   = "NewRevisionAvailable"
+	ConditionReasonRevisionUnavailable = "NewRevisionUnavailable"
+
+	ConditionReasonScheduleAvailable   = "NewScheduleAvailable"
+	ConditionReasonScheduleUnavailable = "NewScheduleUnavailable"
 )
 
 // Condition save the condition info for every condition when call deployment, statefulset and service
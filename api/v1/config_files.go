@@ -0,0 +1,9 @@
+package v1
+
+import "strings"
+
+// Key returns the ConfigMap key of file, it is the path without the leading slash and with
+// the other slashes replaced by underscores, e.g. /etc/nginx/nginx.conf is etc_nginx_nginx.conf
+func (f *ConfigFile) Key() string {
+	return strings.ReplaceAll(strings.TrimLeft(f.Path, "/"), "/", "_")
+}
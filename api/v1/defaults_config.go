@@ -0,0 +1,68 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DefaultsConfig the controller config the defaulting webhook fills spec with.
+// It is loaded from the file given by --defaults-config
+// +kubebuilder:object:generate=false
+type DefaultsConfig struct {
+	// Resources the default resources of all namespaces
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Namespaces the defaults of a namespace, they take precedence over the cluster wide ones
+	Namespaces map[string]NamespaceDefaults `json:"namespaces,omitempty"`
+}
+
+// NamespaceDefaults the defaults of a namespace
+// +kubebuilder:object:generate=false
+type NamespaceDefaults struct {
+	// Resources the default resources of the namespace
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+var defaultsConfig = DefaultsConfig{}
+
+// SetDefaultsConfig sets the config used by the defaulting webhook
+func SetDefaultsConfig(config DefaultsConfig) {
+	defaultsConfig = config
+}
+
+// defaultResources fills the empty requests and limits of resources with the defaults of namespace, then the cluster wide ones
+func defaultResources(resources *corev1.ResourceRequirements, namespace string) {
+	defaults := []corev1.ResourceRequirements{defaultsConfig.Resources}
+	if ns, ok := defaultsConfig.Namespaces[namespace]; ok {
+		defaults = []corev1.ResourceRequirements{ns.Resources, defaultsConfig.Resources}
+	}
+
+	for _, d := range defaults {
+		for name, limit := range d.Limits {
+			if _, ok := resources.Limits[name]; ok {
+				continue
+			}
+			// A default limit lower than the request would make spec invalid, leave it unlimited
+			if request, ok := resources.Requests[name]; ok && limit.Cmp(request) < 0 {
+				continue
+			}
+			if resources.Limits == nil {
+				resources.Limits = corev1.ResourceList{}
+			}
+			resources.Limits[name] = limit.DeepCopy()
+		}
+	}
+	for _, d := range defaults {
+		for name, request := range d.Requests {
+			if _, ok := resources.Requests[name]; ok {
+				continue
+			}
+			if resources.Requests == nil {
+				resources.Requests = corev1.ResourceList{}
+			}
+			// Never request more than the limit
+			if limit, ok := resources.Limits[name]; ok && request.Cmp(limit) > 0 {
+				request = limit
+			}
+			resources.Requests[name] = request.DeepCopy()
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	GatewayPathMatchPathPrefix        = "PathPrefix"
+	GatewayPathMatchExact             = "Exact"
+	GatewayPathMatchRegularExpression = "RegularExpression"
+)
+
+func (r *SingleDeployment) defaultGateway() {
+	if r.Spec.Expose.Gateway == nil {
+		return
+	}
+	for i := range r.Spec.Expose.Gateway.PathMatches {
+		if r.Spec.Expose.Gateway.PathMatches[i].Type == "" {
+			r.Spec.Expose.Gateway.PathMatches[i].Type = GatewayPathMatchPathPrefix
+		}
+	}
+}
+
+func (r *SingleDeployment) validateGateway(gatewayPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	gateway := r.Spec.Expose.Gateway
+	if strings.ToLower(r.Spec.Expose.Mode) != ServiceGateway {
+		if gateway != nil {
+			errs = append(errs,
+				field.Forbidden(gatewayPath, "It can only be set in gateway mode"))
+		}
+		return errs
+	}
+	if gateway == nil {
+		errs = append(errs,
+			field.Required(gatewayPath, "It must be set in gateway mode"))
+		return errs
+	}
+
+	parentRefPath := gatewayPath.Child("parentRef")
+	if gateway.ParentRef.Name == "" {
+		errs = append(errs,
+			field.Required(parentRefPath.Child("name"), "It must be the name of a Gateway"))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(gateway.ParentRef.Name) {
+			errs = append(errs, field.Invalid(parentRefPath.Child("name"), gateway.ParentRef.Name, msg))
+		}
+	}
+	if gateway.ParentRef.Namespace != "" {
+		for _, msg := range validation.IsDNS1123Label(gateway.ParentRef.Namespace) {
+			errs = append(errs, field.Invalid(parentRefPath.Child("namespace"), gateway.ParentRef.Namespace, msg))
+		}
+	}
+
+	for i, hostname := range gateway.Hostnames {
+		var msgs []string
+		if strings.HasPrefix(hostname, "*.") {
+			msgs = validation.IsWildcardDNS1123Subdomain(hostname)
+		} else {
+			msgs = validation.IsDNS1123Subdomain(hostname)
+		}
+		for _, msg := range msgs {
+			errs = append(errs, field.Invalid(gatewayPath.Child("hostnames").Index(i), hostname, msg))
+		}
+	}
+
+	for i, match := range gateway.PathMatches {
+		matchPath := gatewayPath.Child("pathMatches").Index(i)
+		switch match.Type {
+		case GatewayPathMatchPathPrefix, GatewayPathMatchExact, GatewayPathMatchRegularExpression:
+		default:
+			errs = append(errs,
+				field.NotSupported(matchPath.Child("type"), match.Type, []string{GatewayPathMatchPathPrefix, GatewayPathMatchExact, GatewayPathMatchRegularExpression}))
+		}
+		if match.Type != GatewayPathMatchRegularExpression && !strings.HasPrefix(match.Value, "/") {
+			errs = append(errs,
+				field.Invalid(matchPath.Child("value"), match.Value, "It must be an absolute path starting with `/`"))
+		}
+	}
+
+	return errs
+}
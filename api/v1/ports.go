@@ -0,0 +1,71 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DefaultPortName the name of port when the instance has only one port
+const DefaultPortName = "http"
+
+// GetPorts returns spec.ports with defaults filled. If spec.ports is empty, the legacy single port
+// of spec.port and spec.expose is returned, so both styles can be used by the generators
+func (s *SingleDeploymentSpec) GetPorts() []PortSpec {
+	if len(s.Ports) == 0 {
+		if s.Port == 0 {
+			return nil
+		}
+		port := PortSpec{
+			Name:          DefaultPortName,
+			ContainerPort: s.Port,
+			Protocol:      corev1.ProtocolTCP,
+			IngressPath:   "/",
+		}
+		if s.Expose != nil {
+			port.ServicePort = s.Expose.ServicePort
+			port.NodePort = s.Expose.NodePort
+		}
+		if port.ServicePort == 0 {
+			port.ServicePort = s.Port
+		}
+		return []PortSpec{port}
+	}
+
+	ports := make([]PortSpec, len(s.Ports))
+	hasIngressPath := false
+	for i := range s.Ports {
+		ports[i] = *s.Ports[i].DeepCopy()
+		defaultPort(&ports[i], len(s.Ports))
+		if ports[i].IngressPath != "" {
+			hasIngressPath = true
+		}
+	}
+	// The Ingress only routes HTTP, it is sent to the first TCP port
+	if i := FirstTCPPort(ports); !hasIngressPath && i >= 0 {
+		ports[i].IngressPath = "/"
+	}
+
+	return ports
+}
+
+// FirstTCPPort returns the index of the first TCP port, or -1 when all the ports are UDP or SCTP. The ports must
+// be defaulted, e.g. returned by GetPorts
+func FirstTCPPort(ports []PortSpec) int {
+	for i := range ports {
+		if ports[i].Protocol == corev1.ProtocolTCP {
+			return i
+		}
+	}
+	return -1
+}
+
+func defaultPort(port *PortSpec, total int) {
+	if port.Name == "" && total == 1 {
+		port.Name = DefaultPortName
+	}
+	if port.ServicePort == 0 {
+		port.ServicePort = port.ContainerPort
+	}
+	if port.Protocol == "" {
+		port.Protocol = corev1.ProtocolTCP
+	}
+}
@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSingleDeploymentSpec_GetPorts_ingressPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		ports []PortSpec
+		// want is the ingress path of each port
+		want []string
+	}{
+		{
+			name:  "Test case first port is TCP",
+			ports: []PortSpec{{Name: "http", ContainerPort: 80}, {Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP}},
+			want:  []string{"/", ""},
+		},
+		{
+			name:  "Test case first port is UDP",
+			ports: []PortSpec{{Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP}, {Name: "http", ContainerPort: 80}},
+			want:  []string{"", "/"},
+		},
+		{
+			name:  "Test case no TCP port",
+			ports: []PortSpec{{Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP}},
+			want:  []string{""},
+		},
+		{
+			name:  "Test case ingress path is set",
+			ports: []PortSpec{{Name: "http", ContainerPort: 80}, {Name: "api", ContainerPort: 8080, IngressPath: "/api"}},
+			want:  []string{"", "/api"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &SingleDeploymentSpec{Ports: tt.ports}
+			got := s.GetPorts()
+			for i := range got {
+				if got[i].IngressPath != tt.want[i] {
+					t.Errorf("GetPorts()[%d].IngressPath = %v, want %v", i, got[i].IngressPath, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package v1
+
+import "strconv"
+
+// RollbackAnnotation restores the spec recorded as the revision of its value, it is removed after the spec is restored
+const RollbackAnnotation = "deployment.github.com/rollback-to"
+
+// RollbackTarget returns the revision of spec.rollbackTo or the rollback annotation, and false when neither is set
+func (r *SingleDeployment) RollbackTarget() (string, bool) {
+	if r.Spec.RollbackTo != nil {
+		return strconv.FormatInt(*r.Spec.RollbackTo, 10), true
+	}
+	target, ok := r.Annotations[RollbackAnnotation]
+	return target, ok
+}
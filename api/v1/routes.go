@@ -0,0 +1,230 @@
+package v1
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// routeReader lists the SingleDeployments the routes are checked against, it is nil when the webhook is not
+// served by a manager
+var routeReader client.Reader
+
+// IngressHosts returns the hosts of the Ingress in ingress mode, they are the hosts of spec.expose.routes or
+// spec.expose.ingressDomain
+func (s *SingleDeploymentSpec) IngressHosts() []string {
+	if s.Expose == nil {
+		return nil
+	}
+	if len(s.Expose.Routes) == 0 {
+		return []string{s.Expose.IngressDomain}
+	}
+
+	var hosts []string
+	found := map[string]bool{}
+	for _, route := range s.Expose.Routes {
+		if !found[route.Host] {
+			found[route.Host] = true
+			hosts = append(hosts, route.Host)
+		}
+	}
+	return hosts
+}
+
+// ingressPath is a host and path pair of the Ingress
+type ingressPath struct {
+	Host     string
+	Path     string
+	PathType string
+	// Preview the pair is taken by the blue/green preview Ingress
+	Preview bool
+}
+
+// key identifies the pair, the paths equal after cleaned are the same path, e.g. `/api` and `/api/`
+func (p ingressPath) key() string {
+	return p.Host + path.Clean(p.Path) + " " + p.PathType
+}
+
+func (p ingressPath) String() string {
+	return p.Host + p.Path
+}
+
+// ingressPaths returns the host and path pairs the instance takes in ingress mode, the ones of the preview host
+// of blue/green come last
+func (s *SingleDeploymentSpec) ingressPaths() []ingressPath {
+	if s.Expose == nil || strings.ToLower(s.Expose.Mode) != ServiceIngress {
+		return nil
+	}
+
+	paths := s.routePaths()
+	if s.Rollout == nil || s.Rollout.BlueGreen == nil || s.Rollout.BlueGreen.PreviewHost == "" {
+		return paths
+	}
+	// The paths of all hosts are previewed on the preview host, each of them once
+	previewed := map[string]bool{}
+	for _, p := range paths {
+		preview := ingressPath{Host: s.Rollout.BlueGreen.PreviewHost, Path: p.Path, PathType: p.PathType, Preview: true}
+		if !previewed[preview.key()] {
+			previewed[preview.key()] = true
+			paths = append(paths, preview)
+		}
+	}
+	return paths
+}
+
+// routePaths returns the host and path pairs of spec.expose.routes, or of spec.ports under spec.expose.ingressDomain
+func (s *SingleDeploymentSpec) routePaths() []ingressPath {
+	var paths []ingressPath
+	if len(s.Expose.Routes) == 0 {
+		for _, port := range s.GetPorts() {
+			if port.IngressPath != "" {
+				paths = append(paths, ingressPath{Host: s.Expose.IngressDomain, Path: port.IngressPath, PathType: string(netv1.PathTypePrefix)})
+			}
+		}
+		return paths
+	}
+	for _, route := range s.Expose.Routes {
+		p := ingressPath{Host: route.Host, Path: route.Path, PathType: route.PathType}
+		if p.Path == "" {
+			p.Path = "/"
+		}
+		if p.PathType == "" {
+			p.PathType = string(netv1.PathTypePrefix)
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func (r *SingleDeployment) defaultRoutes() {
+	if r.Spec.Expose == nil {
+		return
+	}
+	for i := range r.Spec.Expose.Routes {
+		if r.Spec.Expose.Routes[i].Path == "" {
+			r.Spec.Expose.Routes[i].Path = "/"
+		}
+		if r.Spec.Expose.Routes[i].PathType == "" {
+			r.Spec.Expose.Routes[i].PathType = string(netv1.PathTypePrefix)
+		}
+	}
+}
+
+func (r *SingleDeployment) validateRoutes(routesPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	routes := r.Spec.Expose.Routes
+	if len(routes) == 0 {
+		return errs
+	}
+	if strings.ToLower(r.Spec.Expose.Mode) != ServiceIngress {
+		errs = append(errs,
+			field.Forbidden(routesPath, "It can only be set in ingress mode"))
+		return errs
+	}
+	if r.Spec.Expose.IngressDomain != "" {
+		errs = append(errs,
+			field.Forbidden(routesPath, "It can not be used with `spec.expose.ingressDomain`"))
+	}
+
+	ports := r.Spec.GetPorts()
+	portProtocols := map[string]corev1.Protocol{}
+	for _, port := range ports {
+		portProtocols[port.Name] = port.Protocol
+	}
+	paths := map[string]bool{}
+	for i, route := range routes {
+		routePath := routesPath.Index(i)
+		msgs := validation.IsDNS1123Subdomain(route.Host)
+		if strings.HasPrefix(route.Host, "*.") {
+			msgs = validation.IsWildcardDNS1123Subdomain(route.Host)
+		}
+		for _, msg := range msgs {
+			errs = append(errs, field.Invalid(routePath.Child("host"), route.Host, msg))
+		}
+		if !strings.HasPrefix(route.Path, "/") {
+			errs = append(errs,
+				field.Invalid(routePath.Child("path"), route.Path, "It must be an absolute path starting with `/`"))
+		}
+		switch netv1.PathType(route.PathType) {
+		case netv1.PathTypePrefix, netv1.PathTypeExact, netv1.PathTypeImplementationSpecific:
+		default:
+			errs = append(errs,
+				field.NotSupported(routePath.Child("pathType"), route.PathType, []string{string(netv1.PathTypePrefix), string(netv1.PathTypeExact), string(netv1.PathTypeImplementationSpecific)}))
+		}
+		if protocol, ok := portProtocols[route.Port]; route.Port != "" && !ok {
+			errs = append(errs,
+				field.Invalid(routePath.Child("port"), route.Port, "It must be the name of a port in `spec.ports`"))
+		} else if route.Port != "" && protocol != corev1.ProtocolTCP {
+			errs = append(errs,
+				field.Invalid(routePath.Child("port"), route.Port, "Only TCP port can be exposed by ingress or gateway"))
+		} else if route.Port == "" && FirstTCPPort(ports) < 0 {
+			errs = append(errs,
+				field.Required(routePath.Child("port"), "There is no TCP port in `spec.ports` to be exposed by ingress"))
+		}
+		key := ingressPath{Host: route.Host, Path: route.Path, PathType: route.PathType}.key()
+		if paths[key] {
+			errs = append(errs, field.Duplicate(routePath, route.Host+route.Path))
+		}
+		paths[key] = true
+	}
+
+	return errs
+}
+
+// validateIngressCollision checks the hosts and paths added to instance are not taken by the other
+// SingleDeployments in the cluster, old is nil on create. The paths the instance already has are not checked,
+// so an instance is not blocked by a path taken by both of them before.
+func (r *SingleDeployment) validateIngressCollision(old *SingleDeployment, exposePath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	paths := r.Spec.ingressPaths()
+	if routeReader == nil || len(paths) == 0 {
+		return errs
+	}
+	had := map[string]bool{}
+	if old != nil {
+		for _, p := range old.Spec.ingressPaths() {
+			had[p.key()] = true
+		}
+	}
+
+	list := new(SingleDeploymentList)
+	if err := routeReader.List(context.Background(), list); err != nil {
+		errs = append(errs, field.InternalError(exposePath, err))
+		return errs
+	}
+	taken := map[string]string{}
+	for i := range list.Items {
+		other := &list.Items[i]
+		if other.Namespace == r.Namespace && other.Name == r.Name {
+			continue
+		}
+		for _, p := range other.Spec.ingressPaths() {
+			taken[p.key()] = other.Namespace + "/" + other.Name
+		}
+	}
+
+	for i, p := range paths {
+		owner, ok := taken[p.key()]
+		if !ok || had[p.key()] {
+			continue
+		}
+		// The paths are listed in the order of spec.expose.routes or spec.ports
+		collisionPath := exposePath.Child("ingressDomain")
+		switch {
+		case p.Preview:
+			collisionPath = field.NewPath("spec", "rollout", "blueGreen", "previewHost")
+		case len(r.Spec.Expose.Routes) != 0:
+			collisionPath = exposePath.Child("routes").Index(i)
+		}
+		errs = append(errs,
+			field.Invalid(collisionPath, p.String(), "It is taken by SingleDeployment "+owner))
+	}
+
+	return errs
+}
@@ -0,0 +1,173 @@
+package v1
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_validateIngressCollision(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	newSingleDeployment := func(name string, routes ...IngressRoute) *SingleDeployment {
+		sd := &SingleDeployment{}
+		sd.Name = name
+		sd.Namespace = "default"
+		sd.Spec.Image = "nginx:latest"
+		sd.Spec.Expose = &Expose{Mode: ServiceIngress, Routes: routes}
+		return sd
+	}
+	route := func(host, path, pathType string) IngressRoute {
+		return IngressRoute{Host: host, Path: path, PathType: pathType}
+	}
+
+	withPreview := func(sd *SingleDeployment, host string) *SingleDeployment {
+		sd.Spec.Rollout = &Rollout{BlueGreen: &BlueGreenStrategy{PreviewHost: host}}
+		return sd
+	}
+
+	routeReader = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newSingleDeployment("other", route("example.com", "/api", "Prefix"), route("example.com", "/shared", "Prefix")),
+		newSingleDeployment("instance", route("example.com", "/web", "Prefix"), route("example.com", "/shared", "Prefix")),
+		withPreview(newSingleDeployment("previewed", route("app.example.com", "/", "Prefix")), "preview.example.com"),
+	).Build()
+	defer func() { routeReader = nil }()
+
+	tests := []struct {
+		name    string
+		sd      *SingleDeployment
+		old     *SingleDeployment
+		wantErr bool
+	}{
+		{
+			name:    "Test case create with free path",
+			sd:      newSingleDeployment("new", route("example.com", "/docs", "Prefix")),
+			wantErr: false,
+		},
+		{
+			name:    "Test case create with taken path",
+			sd:      newSingleDeployment("new", route("example.com", "/api", "Prefix")),
+			wantErr: true,
+		},
+		{
+			name:    "Test case create with taken path and trailing slash",
+			sd:      newSingleDeployment("new", route("example.com", "/api/", "Prefix")),
+			wantErr: true,
+		},
+		{
+			name:    "Test case create with taken path of other path type",
+			sd:      newSingleDeployment("new", route("example.com", "/api", "Exact")),
+			wantErr: false,
+		},
+		{
+			name:    "Test case create with taken path of other host",
+			sd:      newSingleDeployment("new", route("other.example.com", "/api", "Prefix")),
+			wantErr: false,
+		},
+		{
+			name:    "Test case update keeps path taken before",
+			sd:      newSingleDeployment("instance", route("example.com", "/web", "Prefix"), route("example.com", "/shared", "Prefix"), route("example.com", "/docs", "Prefix")),
+			old:     newSingleDeployment("instance", route("example.com", "/web", "Prefix"), route("example.com", "/shared", "Prefix")),
+			wantErr: false,
+		},
+		{
+			name:    "Test case update adds taken path",
+			sd:      newSingleDeployment("instance", route("example.com", "/web", "Prefix"), route("example.com", "/api", "Prefix")),
+			old:     newSingleDeployment("instance", route("example.com", "/web", "Prefix"), route("example.com", "/shared", "Prefix")),
+			wantErr: true,
+		},
+		{
+			name:    "Test case create with path taken by preview host",
+			sd:      newSingleDeployment("new", route("preview.example.com", "/", "Prefix")),
+			wantErr: true,
+		},
+		{
+			name:    "Test case create with preview host taking a path",
+			sd:      withPreview(newSingleDeployment("new", route("app.example.com", "/docs", "Prefix"), route("docs.example.com", "/api", "Prefix")), "example.com"),
+			wantErr: true,
+		},
+		{
+			name:    "Test case create with preview host of paths of several hosts",
+			sd:      withPreview(newSingleDeployment("new", route("a.example.com", "/", "Prefix"), route("b.example.com", "/", "Prefix")), "preview.new.example.com"),
+			wantErr: false,
+		},
+		{
+			name:    "Test case not ingress mode",
+			sd:      &SingleDeployment{Spec: SingleDeploymentSpec{Expose: &Expose{Mode: ServiceNodePort}}},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.sd.validateIngressCollision(tt.old, field.NewPath("spec", "expose"))
+			if (len(errs) != 0) != tt.wantErr {
+				t.Errorf("validateIngressCollision() errors = %v, wantErr %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateRoutes_port(t *testing.T) {
+	newSingleDeployment := func(port string, ports ...PortSpec) *SingleDeployment {
+		sd := &SingleDeployment{}
+		sd.Spec.Ports = ports
+		sd.Spec.Expose = &Expose{Mode: ServiceIngress, Routes: []IngressRoute{
+			{Host: "example.com", Path: "/", PathType: "Prefix", Port: port},
+		}}
+		return sd
+	}
+	http := PortSpec{Name: "http", ContainerPort: 80}
+	dns := PortSpec{Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP}
+	sctp := PortSpec{Name: "sctp", ContainerPort: 9999, Protocol: corev1.ProtocolSCTP}
+
+	tests := []struct {
+		name    string
+		sd      *SingleDeployment
+		wantErr bool
+	}{
+		{
+			name:    "Test case TCP port",
+			sd:      newSingleDeployment("http", http, dns),
+			wantErr: false,
+		},
+		{
+			name:    "Test case UDP port",
+			sd:      newSingleDeployment("dns", http, dns),
+			wantErr: true,
+		},
+		{
+			name:    "Test case SCTP port",
+			sd:      newSingleDeployment("sctp", http, sctp),
+			wantErr: true,
+		},
+		{
+			name:    "Test case port not found",
+			sd:      newSingleDeployment("grpc", http, dns),
+			wantErr: true,
+		},
+		{
+			name:    "Test case empty port with TCP port",
+			sd:      newSingleDeployment("", dns, http),
+			wantErr: false,
+		},
+		{
+			name:    "Test case empty port without TCP port",
+			sd:      newSingleDeployment("", dns),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.sd.validateRoutes(field.NewPath("spec", "expose", "routes"))
+			if (len(errs) != 0) != tt.wantErr {
+				t.Errorf("validateRoutes() errors = %v, wantErr %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
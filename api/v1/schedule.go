@@ -0,0 +1,56 @@
+package v1
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	ErrScheduleEvery    = errors.New("@every is not supported, the replicas must be applied at fixed times")
+	ErrScheduleTimeZone = errors.New("the time zone is set by both timeZone and the cron")
+)
+
+// CronSchedule parses the cron of entry in its time zone
+func (e *ScheduleEntry) CronSchedule() (cron.Schedule, error) {
+	spec := strings.TrimSpace(e.Cron)
+	if strings.HasPrefix(spec, "@every") {
+		return nil, ErrScheduleEvery
+	}
+	if e.TimeZone != "" {
+		if strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+			return nil, ErrScheduleTimeZone
+		}
+		spec = "CRON_TZ=" + e.TimeZone + " " + spec
+	}
+
+	return cron.ParseStandard(spec)
+}
+
+func (r *SingleDeployment) validateSchedule(schedulePath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if len(r.Spec.Schedule) == 0 {
+		return errs
+	}
+
+	if r.Spec.Autoscaling != nil {
+		errs = append(errs,
+			field.Forbidden(schedulePath, "It must be empty when `spec.autoscaling` is set"))
+	}
+	for i := range r.Spec.Schedule {
+		entry := &r.Spec.Schedule[i]
+		entryPath := schedulePath.Index(i)
+		if _, err := entry.CronSchedule(); err != nil {
+			errs = append(errs,
+				field.Invalid(entryPath.Child("cron"), entry.Cron, err.Error()))
+		}
+		if entry.Replicas < 0 {
+			errs = append(errs,
+				field.Invalid(entryPath.Child("replicas"), entry.Replicas, "It must be greater than or equal to 0"))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,246 @@
+package v1
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SecurityLevelLabel the label of namespace choosing how strictly spec.securityContext is enforced
+const SecurityLevelLabel = "deployment.github.com/security-level"
+
+const (
+	// SecurityLevelBaseline rejects the known privilege escalations, e.g. privileged containers
+	SecurityLevelBaseline = "baseline"
+	// SecurityLevelRestricted rejects every setting weaker than the defaults of spec.securityContext
+	SecurityLevelRestricted = "restricted"
+)
+
+// CapabilityAll the capability standing for all capabilities
+const CapabilityAll corev1.Capability = "ALL"
+
+// The capabilities the baseline level allows to add, they are the defaults of container runtimes
+var baselineCapabilities = map[corev1.Capability]bool{
+	"AUDIT_WRITE":      true,
+	"CHOWN":            true,
+	"DAC_OVERRIDE":     true,
+	"FOWNER":           true,
+	"FSETID":           true,
+	"KILL":             true,
+	"MKNOD":            true,
+	"NET_BIND_SERVICE": true,
+	"SETFCAP":          true,
+	"SETGID":           true,
+	"SETPCAP":          true,
+	"SETUID":           true,
+	"SYS_CHROOT":       true,
+}
+
+// The capabilities the restricted level allows to add
+var restrictedCapabilities = map[corev1.Capability]bool{
+	"NET_BIND_SERVICE": true,
+}
+
+// namespaceReader reads the security level of namespaces, it is nil when the webhook is not served by a manager
+var namespaceReader client.Reader
+
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+
+// PodSecurityContext returns the pod level settings
+func (s *SecurityContext) PodSecurityContext() *corev1.PodSecurityContext {
+	return &corev1.PodSecurityContext{
+		RunAsNonRoot:   s.RunAsNonRoot,
+		RunAsUser:      s.RunAsUser,
+		RunAsGroup:     s.RunAsGroup,
+		FSGroup:        s.FSGroup,
+		SeccompProfile: s.SeccompProfile,
+	}
+}
+
+// ContainerSecurityContext returns the container level settings
+func (s *SecurityContext) ContainerSecurityContext() *corev1.SecurityContext {
+	return &corev1.SecurityContext{
+		RunAsNonRoot:             s.RunAsNonRoot,
+		RunAsUser:                s.RunAsUser,
+		RunAsGroup:               s.RunAsGroup,
+		ReadOnlyRootFilesystem:   s.ReadOnlyRootFilesystem,
+		AllowPrivilegeEscalation: s.AllowPrivilegeEscalation,
+		Capabilities:             s.Capabilities,
+		SeccompProfile:           s.SeccompProfile,
+	}
+}
+
+// defaultSecurityContext fills the unset items of spec.securityContext with the secure defaults
+func defaultSecurityContext(securityContext **SecurityContext) {
+	if *securityContext == nil {
+		*securityContext = &SecurityContext{}
+	}
+	s := *securityContext
+
+	if s.RunAsNonRoot == nil {
+		runAsNonRoot := true
+		s.RunAsNonRoot = &runAsNonRoot
+	}
+	if s.ReadOnlyRootFilesystem == nil {
+		readOnly := true
+		s.ReadOnlyRootFilesystem = &readOnly
+	}
+	if s.AllowPrivilegeEscalation == nil {
+		allowPrivilegeEscalation := false
+		s.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+	}
+	if s.Capabilities == nil {
+		s.Capabilities = &corev1.Capabilities{Drop: []corev1.Capability{CapabilityAll}}
+	}
+	if s.SeccompProfile == nil {
+		s.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	}
+}
+
+// securityLevel returns the security level of namespace, it is empty when the namespace has no level.
+// An unknown level is enforced as restricted.
+func securityLevel(namespace string) (string, error) {
+	if namespaceReader == nil || namespace == "" {
+		return "", nil
+	}
+
+	ns := new(corev1.Namespace)
+	if err := namespaceReader.Get(context.Background(), types.NamespacedName{Name: namespace}, ns); err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	level, ok := ns.Labels[SecurityLevelLabel]
+	switch {
+	case !ok:
+		return "", nil
+	case level == SecurityLevelBaseline:
+		return SecurityLevelBaseline, nil
+	default:
+		return SecurityLevelRestricted, nil
+	}
+}
+
+func (r *SingleDeployment) validateSecurityContext(specPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if r.Spec.SecurityContext != nil {
+		errs = append(errs, validateSeccompProfile(r.Spec.SecurityContext.SeccompProfile, specPath.Child("securityContext", "seccompProfile"))...)
+	}
+
+	level, err := securityLevel(r.Namespace)
+	if err != nil {
+		return append(errs, field.InternalError(specPath.Child("securityContext"), err))
+	}
+	if level == "" {
+		return errs
+	}
+
+	if r.Spec.SecurityContext != nil {
+		errs = append(errs, validateContainerSecurity(level, r.Spec.SecurityContext.ContainerSecurityContext(), specPath.Child("securityContext"), true)...)
+	}
+	for i := range r.Spec.Sidecars {
+		errs = append(errs, validateContainerSecurity(level, r.Spec.Sidecars[i].SecurityContext, specPath.Child("sidecars").Index(i).Child("securityContext"), false)...)
+	}
+	for i := range r.Spec.InitContainers {
+		errs = append(errs, validateContainerSecurity(level, r.Spec.InitContainers[i].SecurityContext, specPath.Child("initContainers").Index(i).Child("securityContext"), false)...)
+	}
+
+	return errs
+}
+
+func validateSeccompProfile(profile *corev1.SeccompProfile, profilePath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if profile == nil {
+		return errs
+	}
+
+	switch profile.Type {
+	case corev1.SeccompProfileTypeRuntimeDefault, corev1.SeccompProfileTypeUnconfined:
+	case corev1.SeccompProfileTypeLocalhost:
+		if profile.LocalhostProfile == nil || *profile.LocalhostProfile == "" {
+			errs = append(errs,
+				field.Required(profilePath.Child("localhostProfile"), "If type is `Localhost`, it must not be empty"))
+		}
+	default:
+		errs = append(errs,
+			field.NotSupported(profilePath.Child("type"), profile.Type, []string{string(corev1.SeccompProfileTypeRuntimeDefault), string(corev1.SeccompProfileTypeLocalhost), string(corev1.SeccompProfileTypeUnconfined)}))
+	}
+
+	return errs
+}
+
+// validateContainerSecurity rejects the settings weaker than the security level allows. The unset items of
+// a sidecar or init container are filled from spec.securityContext, so they are only checked for the main one
+func validateContainerSecurity(level string, sc *corev1.SecurityContext, scPath *field.Path, main bool) field.ErrorList {
+	errs := field.ErrorList{}
+	if sc == nil {
+		return errs
+	}
+
+	if sc.Privileged != nil && *sc.Privileged {
+		errs = append(errs,
+			field.Forbidden(scPath.Child("privileged"), "It must not be true in a namespace of security level "+level))
+	}
+	if sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+		errs = append(errs,
+			field.Forbidden(scPath.Child("seccompProfile", "type"), "It must not be `Unconfined` in a namespace of security level "+level))
+	}
+	allowed := baselineCapabilities
+	if level == SecurityLevelRestricted {
+		allowed = restrictedCapabilities
+	}
+	if sc.Capabilities != nil {
+		for i, capability := range sc.Capabilities.Add {
+			if !allowed[capability] {
+				errs = append(errs,
+					field.Forbidden(scPath.Child("capabilities", "add").Index(i), "It can not be added in a namespace of security level "+level))
+			}
+		}
+	}
+	if level != SecurityLevelRestricted {
+		return errs
+	}
+
+	if sc.RunAsNonRoot != nil && !*sc.RunAsNonRoot {
+		errs = append(errs,
+			field.Forbidden(scPath.Child("runAsNonRoot"), "It must not be false in a namespace of security level restricted"))
+	}
+	if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+		errs = append(errs,
+			field.Forbidden(scPath.Child("runAsUser"), "It must not be 0 in a namespace of security level restricted"))
+	}
+	if sc.ReadOnlyRootFilesystem != nil && !*sc.ReadOnlyRootFilesystem {
+		errs = append(errs,
+			field.Forbidden(scPath.Child("readOnlyRootFilesystem"), "It must not be false in a namespace of security level restricted"))
+	}
+	if sc.AllowPrivilegeEscalation != nil && *sc.AllowPrivilegeEscalation {
+		errs = append(errs,
+			field.Forbidden(scPath.Child("allowPrivilegeEscalation"), "It must not be true in a namespace of security level restricted"))
+	}
+	if sc.Capabilities != nil && (main || sc.Capabilities.Drop != nil) && !dropsAll(sc.Capabilities) {
+		errs = append(errs,
+			field.Forbidden(scPath.Child("capabilities", "drop"), "It must contain `ALL` in a namespace of security level restricted"))
+	}
+	if main && sc.SeccompProfile == nil {
+		errs = append(errs,
+			field.Required(scPath.Child("seccompProfile"), "It must be `RuntimeDefault` or `Localhost` in a namespace of security level restricted"))
+	}
+
+	return errs
+}
+
+func dropsAll(capabilities *corev1.Capabilities) bool {
+	for _, capability := range capabilities.Drop {
+		if capability == CapabilityAll {
+			return true
+		}
+	}
+	return false
+}
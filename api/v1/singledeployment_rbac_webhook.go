@@ -0,0 +1,193 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// RBACWebhookPath the path of webhook checking the rules of spec.serviceAccount against the permissions of user
+const RBACWebhookPath = "/validate-deployment-github-com-v1-singledeployment-rbac"
+
+//+kubebuilder:webhook:path=/validate-deployment-github-com-v1-singledeployment-rbac,mutating=false,failurePolicy=fail,sideEffects=None,groups=deployment.github.com,resources=singledeployments,verbs=create;update,versions=v1,name=vsingledeploymentrbac.kb.io,admissionReviewVersions=v1
+
+// rbacValidator rejects the rules of spec.serviceAccount which the requesting user does not hold,
+// the controller can create any Role so it must not grant more than the user could
+// +kubebuilder:object:generate=false
+type rbacValidator struct {
+	client client.Client
+	// reader reads the revisions to roll back to from the API server, a revision missed by the cache
+	// would be restored unchecked
+	reader  client.Reader
+	decoder *admission.Decoder
+}
+
+// setupRBACWebhookWithManager registers the webhook checking the rules of spec.serviceAccount
+func setupRBACWebhookWithManager(mgr ctrl.Manager) error {
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+
+	mgr.GetWebhookServer().Register(RBACWebhookPath, &webhook.Admission{
+		Handler: &rbacValidator{client: mgr.GetClient(), reader: mgr.GetAPIReader(), decoder: decoder},
+	})
+	return nil
+}
+
+func (v *rbacValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	sd := new(SingleDeployment)
+	if err := v.decoder.Decode(req, sd); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// Only the new rules are checked, so the others can still update the instance, e.g. the controller
+	var oldRules []rbacv1.PolicyRule
+	if len(req.OldObject.Raw) != 0 {
+		old := new(SingleDeployment)
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if old.Spec.ServiceAccount != nil && old.Spec.ServiceAccount.Create {
+			oldRules = old.Spec.ServiceAccount.Rules
+		}
+	}
+
+	if sd.Spec.ServiceAccount != nil && sd.Spec.ServiceAccount.Create {
+		if response := v.checkRules(ctx, req, sd.Spec.ServiceAccount.Rules, oldRules, "spec.serviceAccount.rules"); !response.Allowed {
+			return response
+		}
+	}
+
+	// The controller restores the revision with its own identity, so the rules of the revision are checked
+	// against the user requesting the rollback
+	if target, ok := sd.RollbackTarget(); ok {
+		spec, err := v.revisionSpec(ctx, sd, target)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if spec != nil && spec.ServiceAccount != nil && spec.ServiceAccount.Create {
+			if response := v.checkRules(ctx, req, spec.ServiceAccount.Rules, oldRules,
+				fmt.Sprintf("revision %s to roll back to: spec.serviceAccount.rules", target)); !response.Allowed {
+				return response
+			}
+		}
+	}
+
+	return admission.Allowed("")
+}
+
+// checkRules denies the rules not in oldRules which the requesting user does not hold
+func (v *rbacValidator) checkRules(ctx context.Context, req admission.Request, rules, oldRules []rbacv1.PolicyRule, rulesPath string) admission.Response {
+	for i, rule := range rules {
+		if containsRule(oldRules, &rule) {
+			continue
+		}
+		for _, attributes := range ruleAttributes(&rule, req.Namespace) {
+			review := &authorizationv1.SubjectAccessReview{
+				Spec: authorizationv1.SubjectAccessReviewSpec{
+					ResourceAttributes: attributes,
+					User:               req.UserInfo.Username,
+					Groups:             req.UserInfo.Groups,
+					UID:                req.UserInfo.UID,
+				},
+			}
+			if len(req.UserInfo.Extra) != 0 {
+				review.Spec.Extra = make(map[string]authorizationv1.ExtraValue, len(req.UserInfo.Extra))
+				for key, value := range req.UserInfo.Extra {
+					review.Spec.Extra[key] = authorizationv1.ExtraValue(value)
+				}
+			}
+			if err := v.client.Create(ctx, review); err != nil {
+				return admission.Errored(http.StatusInternalServerError, err)
+			}
+			if !review.Status.Allowed {
+				return admission.Denied(fmt.Sprintf(
+					"%s[%d]: Forbidden: user \"%s\" can not %s %s in group \"%s\", it can not be granted",
+					rulesPath, i, req.UserInfo.Username, attributes.Verb, attributes.Resource, attributes.Group))
+			}
+		}
+	}
+
+	return admission.Allowed("")
+}
+
+// revisionSpec returns the spec recorded as the revision of instance, and nil when it is not found
+func (v *rbacValidator) revisionSpec(ctx context.Context, sd *SingleDeployment, target string) (*SingleDeploymentSpec, error) {
+	list := new(appsv1.ControllerRevisionList)
+	if err := v.reader.List(ctx, list,
+		client.InNamespace(sd.Namespace),
+		client.MatchingLabels{"app": sd.Name},
+	); err != nil {
+		return nil, err
+	}
+
+	for i := range list.Items {
+		revision := &list.Items[i]
+		if !metav1.IsControlledBy(revision, sd) || strconv.FormatInt(revision.Revision, 10) != target {
+			continue
+		}
+		spec := new(SingleDeploymentSpec)
+		if err := json.Unmarshal(revision.Data.Raw, spec); err != nil {
+			return nil, fmt.Errorf("ControllerRevision \"%s\" decode failed: %s", revision.Name, err.Error())
+		}
+		return spec, nil
+	}
+
+	return nil, nil
+}
+
+func containsRule(rules []rbacv1.PolicyRule, rule *rbacv1.PolicyRule) bool {
+	for i := range rules {
+		if reflect.DeepEqual(&rules[i], rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleAttributes expands a rule into the attributes of every verb on every resource it grants
+func ruleAttributes(rule *rbacv1.PolicyRule, namespace string) []*authorizationv1.ResourceAttributes {
+	names := rule.ResourceNames
+	if len(names) == 0 {
+		names = []string{""}
+	}
+
+	var attributes []*authorizationv1.ResourceAttributes
+	for _, group := range rule.APIGroups {
+		for _, resource := range rule.Resources {
+			// A subresource is granted as <resource>/<subresource>
+			subresource := ""
+			if parts := strings.SplitN(resource, "/", 2); len(parts) == 2 {
+				resource, subresource = parts[0], parts[1]
+			}
+			for _, verb := range rule.Verbs {
+				for _, name := range names {
+					attributes = append(attributes, &authorizationv1.ResourceAttributes{
+						Namespace:   namespace,
+						Verb:        verb,
+						Group:       group,
+						Resource:    resource,
+						Subresource: subresource,
+						Name:        name,
+					})
+				}
+			}
+		}
+	}
+
+	return attributes
+}
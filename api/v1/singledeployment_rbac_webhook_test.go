@@ -0,0 +1,151 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// denyingClient answers every SubjectAccessReview as not allowed
+type denyingClient struct {
+	client.Client
+}
+
+func (c *denyingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+		review.Status.Allowed = false
+		return nil
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func Test_rbacValidator_Handle_rollback(t *testing.T) {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{AddToScheme, appsv1.AddToScheme, authorizationv1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sd := &SingleDeployment{}
+	sd.Name = "singledeployment-sample"
+	sd.Namespace = "system"
+	sd.UID = types.UID("singledeployment-sample")
+	sd.Spec.Image = "nginx:latest"
+
+	// Every rule not held before is denied by denyingClient
+	newRevision := func(revision int64, rules []rbacv1.PolicyRule) *appsv1.ControllerRevision {
+		spec := sd.Spec.DeepCopy()
+		if rules != nil {
+			spec.ServiceAccount = &ServiceAccount{Create: true, Rules: rules}
+		}
+		raw, err := json.Marshal(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		isController := true
+		cr := &appsv1.ControllerRevision{}
+		cr.Name = fmt.Sprintf("%s-%d", sd.Name, revision)
+		cr.Namespace = sd.Namespace
+		cr.Labels = map[string]string{"app": sd.Name}
+		cr.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: GroupVersion.String(),
+			Kind:       "SingleDeployment",
+			Name:       sd.Name,
+			UID:        sd.UID,
+			Controller: &isController,
+		}}
+		cr.Revision = revision
+		cr.Data.Raw = raw
+		return cr
+	}
+	rules := []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}}
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newRevision(1, rules),
+		newRevision(2, nil),
+	).Build()
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &rbacValidator{
+		client:  &denyingClient{Client: fake.NewClientBuilder().WithScheme(scheme).Build()},
+		reader:  reader,
+		decoder: decoder,
+	}
+
+	rollbackTo := func(revision int64) *SingleDeployment {
+		rollback := sd.DeepCopy()
+		rollback.Spec.RollbackTo = &revision
+		return rollback
+	}
+	annotated := sd.DeepCopy()
+	annotated.Annotations = map[string]string{RollbackAnnotation: "1"}
+
+	tests := []struct {
+		name        string
+		sd          *SingleDeployment
+		wantAllowed bool
+	}{
+		{
+			name:        "Test case rollback to revision with rules the user does not hold",
+			sd:          rollbackTo(1),
+			wantAllowed: false,
+		},
+		{
+			name:        "Test case rollback annotation to revision with rules the user does not hold",
+			sd:          annotated,
+			wantAllowed: false,
+		},
+		{
+			name:        "Test case rollback to revision without rules",
+			sd:          rollbackTo(2),
+			wantAllowed: true,
+		},
+		{
+			name:        "Test case rollback to revision not found",
+			sd:          rollbackTo(3),
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(tt.sd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			oldRaw, err := json.Marshal(sd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Update,
+				Namespace: sd.Namespace,
+				Object:    runtime.RawExtension{Raw: raw},
+				OldObject: runtime.RawExtension{Raw: oldRaw},
+			}}
+			req.UserInfo.Username = "developer"
+
+			got := v.Handle(context.Background(), req)
+			if got.Allowed != tt.wantAllowed {
+				t.Errorf("Handle() allowed = %v, want %v, result %v", got.Allowed, tt.wantAllowed, got.Result)
+			}
+			if !got.Allowed && got.Result.Code != http.StatusForbidden {
+				t.Errorf("Handle() is not denied by the rules, result %v", got.Result)
+			}
+		})
+	}
+}
@@ -17,8 +17,12 @@ limitations under the License.
 package v1
 
 import (
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -33,13 +37,38 @@ type SingleDeploymentSpec struct {
 	//+optional
 	Image string `json:"image,omitempty"`
 
-	// Port The port this instance accesses, and the port you want to expose
-	Port int32 `json:"port"`
+	// Port The port this instance accesses, and the port you want to expose. If spec.ports is set, it is the first TCP one of them
+	//+optional
+	Port int32 `json:"port,omitempty"`
+
+	// Ports the named ports this instance accesses, and how they are exposed. If empty, spec.port and spec.expose are used
+	//+optional
+	Ports []PortSpec `json:"ports,omitempty"`
 
 	// Replicas How many replicas you want deployment, default is 1
 	//+optional
 	Replicas int32 `json:"replicas,omitempty"`
 
+	// Suspend scales the instance to 0 and keeps its Service, Ingress and node ports. The replicas, or
+	// the HorizontalPodAutoscaler of spec.autoscaling, are restored when it is set back to false
+	//+optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Schedule changes the replicas of instance by cron, the latest entry fired replaces spec.replicas.
+	// It can not be set with spec.autoscaling
+	//+optional
+	Schedule []ScheduleEntry `json:"schedule,omitempty"`
+
+	// Autoscaling scales the instance by a HorizontalPodAutoscaler managed by controller. The replicas of
+	// deployment are left to the HorizontalPodAutoscaler when it is set
+	//+optional
+	Autoscaling *Autoscaling `json:"autoscaling,omitempty"`
+
+	// DisruptionBudget the PodDisruptionBudget of instance. It is created with maxUnavailable 1 by default
+	// when the instance has more than 1 replica, and it is removed when the instance has only 1 replica
+	//+optional
+	DisruptionBudget *DisruptionBudget `json:"disruptionBudget,omitempty"`
+
 	// StartCmd Start command, if empty, use the buit-in CMD/ENTRYPOINT
 	//+optional
 	StartCmd string `json:"startCmd,omitempty"`
@@ -48,37 +77,578 @@ type SingleDeploymentSpec struct {
 	//+optional
 	Args []string `json:"args,omitempty"`
 
+	// Shell Run startCmd through `/bin/sh -c` instead of splitting it into words. In this mode args are passed to the shell as positional parameters ($1, $2...)
+	//+optional
+	Shell bool `json:"shell,omitempty"`
+
 	// Environments is the environment variable pair(name, value) when the instance is running, so it must be even.
 	//+optional
 	Environments []corev1.EnvVar `json:"environments,omitempty"`
 
+	// EnvFrom the Secrets and ConfigMaps whose keys are all set as environment variables.
+	// The instance is restarted when their data changes
+	//+optional
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Resources the compute resources requests and limits of instance. The empty items are filled with the defaults of controller
+	//+optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Storage the persistent volumes mounted into the instance, their PersistentVolumeClaims are managed by controller
+	//+optional
+	Storage []Storage `json:"storage,omitempty"`
+
+	// ConfigFiles the files rendered into a ConfigMap managed by controller and mounted into the container.
+	// The instance is restarted when their content changes
+	//+optional
+	ConfigFiles []ConfigFile `json:"configFiles,omitempty"`
+
+	// Sidecars the containers running beside the instance container in every pod
+	//+optional
+	Sidecars []Sidecar `json:"sidecars,omitempty"`
+
+	// InitContainers the containers running in order before the instance container starts, e.g. database migrations
+	//+optional
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+
+	// ImagePullSecrets the kubernetes.io/dockerconfigjson Secrets used to pull the images of instance
+	//+optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// RegistryAuth builds an image pull Secret managed by controller from a username and password
+	//+optional
+	RegistryAuth *RegistryAuth `json:"registryAuth,omitempty"`
+
+	// ServiceAccount the ServiceAccount the pods run as, default is the default ServiceAccount of namespace
+	//+optional
+	ServiceAccount *ServiceAccount `json:"serviceAccount,omitempty"`
+
+	// SecurityContext the security settings of the pods and containers of instance. The unset items are
+	// filled with secure defaults: non root, read only root filesystem, no capabilities and RuntimeDefault seccomp
+	//+optional
+	SecurityContext *SecurityContext `json:"securityContext,omitempty"`
+
+	// Scheduling where the pods of instance are scheduled
+	//+optional
+	Scheduling *Scheduling `json:"scheduling,omitempty"`
+
+	// Rollout how a new pod template replaces the running one. The Deployment rolling update is used when it is empty
+	//+optional
+	Rollout *Rollout `json:"rollout,omitempty"`
+
+	// RevisionHistoryLimit how many applied specs are kept as ControllerRevisions for rollback. Default is 10
+	//+optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+
+	// RollbackTo restores the spec recorded as this revision, see status.currentRevision. It is removed
+	// once the spec is restored
+	//+optional
+	RollbackTo *int64 `json:"rollbackTo,omitempty"`
+
+	// HealthCheck the probes of instance. If readiness is empty, a TCP probe on spec.port is used
+	//+optional
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
+
 	// Expose your instance
 	Expose *Expose `json:"expose"`
+
+	// Build the image from source. It only works when spec.image is empty
+	//+optional
+	Build *Build `json:"build,omitempty"`
+}
+
+// Autoscaling defines the HorizontalPodAutoscaler of instance
+type Autoscaling struct {
+	// MinReplicas the lower limit of replicas, default is spec.replicas
+	//+optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas the upper limit of replicas
+	MaxReplicas int32 `json:"maxReplicas"`
+	// TargetCPUUtilizationPercentage the target average CPU utilization of the requested CPU.
+	// It is 80 when no target is set
+	//+optional
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+	// TargetMemoryUtilizationPercentage the target average memory utilization of the requested memory
+	//+optional
+	TargetMemoryUtilizationPercentage *int32 `json:"targetMemoryUtilizationPercentage,omitempty"`
+	// Metrics the custom metric targets, they are used besides the CPU and memory targets
+	//+optional
+	Metrics []autoscalingv2.MetricSpec `json:"metrics,omitempty"`
+}
+
+// ScheduleEntry defines the replicas of instance from a time
+type ScheduleEntry struct {
+	// Cron when the replicas are applied, in the standard 5 fields format, e.g. `0 20 * * 1-5`
+	Cron string `json:"cron"`
+	// Replicas the replicas of instance after the cron fired
+	Replicas int32 `json:"replicas"`
+	// TimeZone the IANA time zone of cron, e.g. `Asia/Shanghai`. Default is UTC
+	//+optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// DisruptionBudget defines how many pods of instance can be evicted at once, only one of them can be set
+type DisruptionBudget struct {
+	// MinAvailable the number or percentage of pods that must be available after an eviction
+	//+optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	// MaxUnavailable the number or percentage of pods that can be unavailable after an eviction
+	//+optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// Build defines how to build the image of instance from source
+type Build struct {
+	// Source where the build context comes from
+	Source BuildSource `json:"source"`
+	// ContextDir the sub directory of source used as build context, default is the root of source
+	//+optional
+	ContextDir string `json:"contextDir,omitempty"`
+	// Dockerfile the path of Dockerfile relative to the build context, default is Dockerfile
+	//+optional
+	Dockerfile string `json:"dockerfile,omitempty"`
+	// Registry the image repository the built image is pushed to, e.g. registry.example.com/team/app. It must not contain a tag or digest
+	Registry string `json:"registry"`
+	// PushSecret the name of a kubernetes.io/dockerconfigjson Secret used to push the image to registry
+	//+optional
+	PushSecret string `json:"pushSecret,omitempty"`
+	// Builder which builder runs the build, is kaniko or buildkit, default is kaniko
+	//+optional
+	Builder string `json:"builder,omitempty"`
+	// BuilderImage override the default image of builder
+	//+optional
+	BuilderImage string `json:"builderImage,omitempty"`
+}
+
+// BuildSource defines the build context, only one of git, configMap and persistentVolumeClaim can be set
+type BuildSource struct {
+	// Git clone the build context from a git repository
+	//+optional
+	Git *GitSource `json:"git,omitempty"`
+	// ConfigMap use the files of a ConfigMap as build context
+	//+optional
+	ConfigMap *corev1.LocalObjectReference `json:"configMap,omitempty"`
+	// PersistentVolumeClaim use the files of a PersistentVolumeClaim as build context
+	//+optional
+	PersistentVolumeClaim *PersistentVolumeClaimSource `json:"persistentVolumeClaim,omitempty"`
+}
+
+// GitSource defines a git repository used as build context
+type GitSource struct {
+	// URL the url of git repository
+	URL string `json:"url"`
+	// Revision branch, tag or commit checked out before build, default is the default branch
+	//+optional
+	Revision string `json:"revision,omitempty"`
+}
+
+// PersistentVolumeClaimSource defines a PersistentVolumeClaim used as build context
+type PersistentVolumeClaimSource struct {
+	// ClaimName the name of PersistentVolumeClaim in the same namespace
+	ClaimName string `json:"claimName"`
+	// SubPath the path within the volume used as build context root
+	//+optional
+	SubPath string `json:"subPath,omitempty"`
+}
+
+// PortSpec defines a port of instance and how it is exposed
+type PortSpec struct {
+	// Name the name of port, it must be unique. It is required when there are more than one ports, default is http
+	//+optional
+	Name string `json:"name,omitempty"`
+	// ContainerPort the port the instance listens on
+	ContainerPort int32 `json:"containerPort"`
+	// ServicePort the port of service. If it is empty, set to be containerPort
+	//+optional
+	ServicePort int32 `json:"servicePort,omitempty"`
+	// Protocol is TCP, UDP or SCTP, default is TCP
+	//+optional
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+	// AppProtocol the application protocol of port, e.g. http, grpc
+	//+optional
+	AppProtocol *string `json:"appProtocol,omitempty"`
+	// NodePort the port is exposed with the node port number in nodeport mode. If it is empty, kubernetes allocates one
+	//+optional
+	NodePort int32 `json:"nodePort,omitempty"`
+	// IngressPath the port is exposed under the path of spec.expose.ingressDomain in ingress mode, or of the HTTPRoute in gateway mode.
+	// If no port sets it, the first port is exposed on `/`
+	//+optional
+	IngressPath string `json:"ingressPath,omitempty"`
+}
+
+// Storage defines a PersistentVolumeClaim managed by controller and where it is mounted
+type Storage struct {
+	// Name the name of storage, the PersistentVolumeClaim is named <instance name>-<name>
+	Name string `json:"name"`
+	// Size the requested size of volume, e.g. 10Gi. It can only be increased
+	Size resource.Quantity `json:"size"`
+	// StorageClassName the StorageClass of volume, default is the default StorageClass of cluster
+	//+optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// AccessModes the access modes of volume, default is ReadWriteOnce. The instance is updated by recreating when it is ReadWriteOnce
+	//+optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+	// MountPath the path the volume is mounted at in the container
+	MountPath string `json:"mountPath"`
+	// DeletePolicy is Delete or Retain, default is Delete. With Retain the PersistentVolumeClaim is kept when the instance is deleted or the storage is removed
+	//+optional
+	DeletePolicy string `json:"deletePolicy,omitempty"`
+}
+
+// ConfigFile defines a file mounted into the container
+type ConfigFile struct {
+	// Path the absolute path of file in the container
+	Path string `json:"path"`
+	// Content the content of file
+	//+optional
+	Content string `json:"content,omitempty"`
+}
+
+// Sidecar defines a container running beside the instance container
+type Sidecar struct {
+	corev1.Container `json:",inline"`
+	// InheritEnvironments sets spec.environments to the sidecar before its own env, default is true
+	//+optional
+	InheritEnvironments *bool `json:"inheritEnvironments,omitempty"`
+}
+
+// SecurityContext defines the security settings of instance. The container settings are applied to the
+// sidecars and init containers too, unless they set their own
+type SecurityContext struct {
+	// RunAsNonRoot the containers must run as a non root user, default is true
+	//+optional
+	RunAsNonRoot *bool `json:"runAsNonRoot,omitempty"`
+	// RunAsUser the UID the containers run as, default is the user of image
+	//+optional
+	RunAsUser *int64 `json:"runAsUser,omitempty"`
+	// RunAsGroup the GID the containers run as, default is the group of image
+	//+optional
+	RunAsGroup *int64 `json:"runAsGroup,omitempty"`
+	// FSGroup the group owning the mounted volumes
+	//+optional
+	FSGroup *int64 `json:"fsGroup,omitempty"`
+	// ReadOnlyRootFilesystem mounts the root filesystem read only, default is true. An emptyDir is mounted
+	// at /tmp when it is true
+	//+optional
+	ReadOnlyRootFilesystem *bool `json:"readOnlyRootFilesystem,omitempty"`
+	// AllowPrivilegeEscalation a process can gain more privileges than its parent, default is false
+	//+optional
+	AllowPrivilegeEscalation *bool `json:"allowPrivilegeEscalation,omitempty"`
+	// Capabilities the capabilities added and dropped, default is dropping ALL
+	//+optional
+	Capabilities *corev1.Capabilities `json:"capabilities,omitempty"`
+	// SeccompProfile the seccomp profile of pods, default is RuntimeDefault
+	//+optional
+	SeccompProfile *corev1.SeccompProfile `json:"seccompProfile,omitempty"`
+}
+
+// RegistryAuth defines the credentials of a private registry
+type RegistryAuth struct {
+	// Server the registry host of images, e.g. registry.example.com
+	Server string `json:"server"`
+	// SecretName the Secret in the namespace of instance which holds the username and password
+	SecretName string `json:"secretName"`
+	// UsernameKey the key of username in the Secret, default is username
+	//+optional
+	UsernameKey string `json:"usernameKey,omitempty"`
+	// PasswordKey the key of password in the Secret, default is password
+	//+optional
+	PasswordKey string `json:"passwordKey,omitempty"`
+}
+
+// ServiceAccount defines the identity of instance pods
+type ServiceAccount struct {
+	// Name the name of ServiceAccount. It must exist when create is false, and it is the instance name by default when create is true
+	//+optional
+	Name string `json:"name,omitempty"`
+	// Create the ServiceAccount is created and owned by controller
+	//+optional
+	Create bool `json:"create,omitempty"`
+	// Rules the permissions granted to the created ServiceAccount by a Role in the namespace of instance.
+	// The user must hold the permissions to grant them
+	//+optional
+	Rules []rbacv1.PolicyRule `json:"rules,omitempty"`
+}
+
+// Scheduling defines the scheduling constraints of instance pods
+type Scheduling struct {
+	// NodeSelector the labels a node must have to run the pods
+	//+optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations the taints of nodes the pods tolerate
+	//+optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// Affinity the node affinity, pod affinity and pod anti-affinity of pods
+	//+optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// SpreadAcrossZones spreads the pods evenly across the zones of topology.kubernetes.io/zone
+	//+optional
+	SpreadAcrossZones bool `json:"spreadAcrossZones,omitempty"`
+	// SpreadAcrossNodes spreads the pods evenly across the nodes of kubernetes.io/hostname
+	//+optional
+	SpreadAcrossNodes bool `json:"spreadAcrossNodes,omitempty"`
+}
+
+// Rollout defines the strategy rolling out a new pod template
+type Rollout struct {
+	// Canary runs the new pod template beside the running one, and shifts the traffic to it by steps.
+	// It works with spec.expose.mode `ingress` only, the traffic is split by ingress-nginx
+	//+optional
+	Canary *CanaryStrategy `json:"canary,omitempty"`
+	// BlueGreen runs the new pod template in a full parallel Deployment, and switches the Service to it
+	// when it is promoted. Only one of canary and blueGreen can be set
+	//+optional
+	BlueGreen *BlueGreenStrategy `json:"blueGreen,omitempty"`
+}
+
+// BlueGreenStrategy defines the blue/green switch of a new pod template
+type BlueGreenStrategy struct {
+	// AutoPromote switches the Service once the preview is available. Otherwise it waits until the instance
+	// is annotated with `deployment.github.com/rollout: promote`
+	//+optional
+	AutoPromote bool `json:"autoPromote,omitempty"`
+	// ScaleDownDelay how long the previous pods are kept after the switch, the switch can be aborted
+	// instantly in this time. Default is 30s
+	//+optional
+	ScaleDownDelay *metav1.Duration `json:"scaleDownDelay,omitempty"`
+	// PreviewHost the host of preview Ingress in ingress mode, default is preview.<spec.expose.ingressDomain>
+	//+optional
+	PreviewHost string `json:"previewHost,omitempty"`
+}
+
+// CanaryStrategy defines the canary of a new pod template
+type CanaryStrategy struct {
+	// Replicas the replicas of canary Deployment, default is 1
+	//+optional
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Steps run in order, the new pod template replaces the running one after the last step
+	Steps []CanaryStep `json:"steps"`
+}
+
+// CanaryStep defines a step of canary, exactly one of its items must be set
+type CanaryStep struct {
+	// Weight sends the percentage of traffic to the canary, 0-100
+	//+optional
+	Weight *int32 `json:"weight,omitempty"`
+	// Pause waits for the duration before the next step
+	//+optional
+	Pause *metav1.Duration `json:"pause,omitempty"`
+	// Approval waits until the instance is annotated with `deployment.github.com/rollout: promote`
+	//+optional
+	Approval bool `json:"approval,omitempty"`
+}
+
+// HealthCheck defines the probes of instance
+type HealthCheck struct {
+	// Liveness the container is restarted when this probe fails
+	//+optional
+	Liveness *Probe `json:"liveness,omitempty"`
+	// Readiness the instance is removed from service when this probe fails. If empty, a TCP probe on spec.port is used
+	//+optional
+	Readiness *Probe `json:"readiness,omitempty"`
+	// Startup the other probes are hold until this probe succeeds, use it for slow starting instance
+	//+optional
+	Startup *Probe `json:"startup,omitempty"`
+}
+
+// Probe defines how to check the health of instance, only one of httpGet, tcpSocket and exec can be set
+type Probe struct {
+	// HTTPGet probe by a HTTP GET request, any code in 200-399 is success
+	//+optional
+	HTTPGet *HTTPGetProbe `json:"httpGet,omitempty"`
+	// TCPSocket probe by opening a TCP connection
+	//+optional
+	TCPSocket *TCPSocketProbe `json:"tcpSocket,omitempty"`
+	// Exec probe by running a command in the container, exit code 0 is success
+	//+optional
+	Exec *ExecProbe `json:"exec,omitempty"`
+	// InitialDelaySeconds seconds after the container has started before the probe is initiated
+	//+optional
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+	// PeriodSeconds how often to perform the probe, default is 10
+	//+optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+	// TimeoutSeconds seconds after which the probe times out, default is 1
+	//+optional
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+	// SuccessThreshold minimum consecutive successes to be considered successful after having failed, default is 1. It must be 1 for liveness and startup
+	//+optional
+	SuccessThreshold int32 `json:"successThreshold,omitempty"`
+	// FailureThreshold minimum consecutive failures to be considered failed after having succeeded, default is 3
+	//+optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+}
+
+// HTTPGetProbe defines a HTTP GET probe
+type HTTPGetProbe struct {
+	// Path the path to request, e.g. /healthz
+	Path string `json:"path"`
+	// Port the port to request, default is spec.port
+	//+optional
+	Port int32 `json:"port,omitempty"`
+	// Scheme is HTTP or HTTPS, default is HTTP
+	//+optional
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// TCPSocketProbe defines a TCP probe
+type TCPSocketProbe struct {
+	// Port the port to connect, default is spec.port
+	//+optional
+	Port int32 `json:"port,omitempty"`
+}
+
+// ExecProbe defines a command probe
+type ExecProbe struct {
+	// Command the command to run, it is not run in a shell
+	Command []string `json:"command"`
 }
 
 // Expose defines the desired state of expose instance
 type Expose struct {
-	// Mode deployment mode, is NodePort or Ingress
+	// Mode deployment mode, is NodePort, Ingress, LoadBalancer, Gateway, Internal or None. The instance is only
+	// accessed in cluster by a ClusterIP Service in internal mode, and has no Service in none mode
 	Mode string `json:"mode"`
 
 	// IngressDomain the instance will be added to ingress and accessed through the unified portal.
 	//+optional
 	IngressDomain string `json:"ingressDomain,omitempty"`
 
-	// NodePort the install will be expose by NodePort mode with the port number
+	// Routes the hosts and paths of the Ingress in ingress mode, several instances can share a host under
+	// different paths. It replaces spec.expose.ingressDomain and the ingressPath of spec.ports
+	//+optional
+	Routes []IngressRoute `json:"routes,omitempty"`
+
+	// NodePort the install will be expose by NodePort mode with the port number. It can not be used with spec.ports
 	//+optional
 	NodePort int32 `json:"nodePort,omitempty"`
 
-	// ServicePort the service resource use the port. If it is empty, set to be spec.port
+	// ServicePort the service resource use the port. If it is empty, set to be spec.port. It can not be used with spec.ports
 	//+optional
 	ServicePort int32 `json:"servicePort,omitempty"`
+
+	// LoadBalancerIP the address asked from the load balancer in loadbalancer mode, if the cloud provider supports it
+	//+optional
+	LoadBalancerIP string `json:"loadBalancerIP,omitempty"`
+
+	// SourceRanges the CIDRs allowed to access the load balancer in loadbalancer mode
+	//+optional
+	SourceRanges []string `json:"sourceRanges,omitempty"`
+
+	// ExternalTrafficPolicy Cluster or Local, how the traffic from outside of cluster is routed in nodeport and loadbalancer modes
+	//+optional
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicyType `json:"externalTrafficPolicy,omitempty"`
+
+	// Annotations of the Service in loadbalancer mode, e.g. the load balancer settings of cloud provider
+	//+optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Gateway the HTTPRoute of Gateway API in gateway mode
+	//+optional
+	Gateway *GatewayExpose `json:"gateway,omitempty"`
+
+	// TLS serves spec.expose.ingressDomain over HTTPS in ingress mode
+	//+optional
+	TLS *IngressTLS `json:"tls,omitempty"`
+}
+
+// IngressRoute defines a host and path of the Ingress routed to a port of instance
+type IngressRoute struct {
+	// Host the domain of the route
+	Host string `json:"host"`
+
+	// Path the path of the route, default is `/`
+	//+optional
+	Path string `json:"path,omitempty"`
+
+	// PathType Prefix, Exact or ImplementationSpecific, default is Prefix
+	//+optional
+	PathType string `json:"pathType,omitempty"`
+
+	// Port the name of the TCP port in spec.ports the route is sent to, default is the first TCP port
+	//+optional
+	Port string `json:"port,omitempty"`
+
+	// RewriteTarget the path the requests of the route are rewritten to by ingress-nginx, e.g. `/$2`. The routes
+	// of each rewrite target are served by an Ingress of their own
+	//+optional
+	RewriteTarget string `json:"rewriteTarget,omitempty"`
+}
+
+// IngressTLS defines the certificate of the Ingress, it is an existing Secret or issued by cert-manager
+type IngressTLS struct {
+	// SecretName the Secret of type kubernetes.io/tls holding the certificate. With issuerRef it is the
+	// Secret cert-manager issues the certificate to, default is `<name>-tls`
+	//+optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// IssuerRef the cert-manager Issuer or ClusterIssuer issuing the certificate
+	//+optional
+	IssuerRef *IssuerRef `json:"issuerRef,omitempty"`
+
+	// CreateCertificate the controller creates a cert-manager Certificate owned by the instance. Otherwise the
+	// Ingress is annotated, and the Certificate is created by cert-manager
+	//+optional
+	CreateCertificate bool `json:"createCertificate,omitempty"`
+
+	// ForceSSLRedirect redirects HTTP requests to HTTPS
+	//+optional
+	ForceSSLRedirect bool `json:"forceSSLRedirect,omitempty"`
+}
+
+// IssuerRef defines a cert-manager issuer
+type IssuerRef struct {
+	// Name of the issuer
+	Name string `json:"name"`
+
+	// Kind Issuer or ClusterIssuer, default is Issuer
+	//+optional
+	Kind string `json:"kind,omitempty"`
+}
+
+// GatewayExpose defines the HTTPRoute exposing the instance by a Gateway
+type GatewayExpose struct {
+	// ParentRef the Gateway the HTTPRoute is attached to
+	ParentRef GatewayParentRef `json:"parentRef"`
+
+	// Hostnames the hostnames the HTTPRoute matches, it matches the hostnames of Gateway listener when empty
+	//+optional
+	Hostnames []string `json:"hostnames,omitempty"`
+
+	// PathMatches the paths routed to the first port exposed. Default is the PathPrefix of spec.ports[*].ingressPath
+	//+optional
+	PathMatches []GatewayPathMatch `json:"pathMatches,omitempty"`
+}
+
+// GatewayParentRef defines the Gateway an HTTPRoute is attached to
+type GatewayParentRef struct {
+	// Name of the Gateway
+	Name string `json:"name"`
+
+	// Namespace of the Gateway, default is the namespace of instance
+	//+optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// SectionName the listener of the Gateway, all listeners are used when it is empty
+	//+optional
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// GatewayPathMatch defines how the path of a request is matched
+type GatewayPathMatch struct {
+	// Type PathPrefix, Exact or RegularExpression, default is PathPrefix
+	//+optional
+	Type string `json:"type,omitempty"`
+
+	// Value the path, e.g. `/api`
+	Value string `json:"value"`
 }
 
 // SingleDeploymentStatus defines the observed state of SingleDeployment
 type SingleDeploymentStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
-	// Phase Execution phase: Creating | Running | Success | Failed | Deleting
+	// Phase Execution phase: Creating | Running | Success | Failed | Deleting | Suspended
 	// +optional
 	Phase string `json:"phase,omitempty"`
 
@@ -94,9 +664,88 @@ type SingleDeploymentStatus struct {
 	// +optional
 	Conditions []Condition `json:"conditions,omitempty"`
 
-	// Reversions update recorder
+	// ObservedGeneration the counter of status updates, the applied specs are recorded by currentRevision
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// CurrentRevision the revision of ControllerRevision recording the applied spec
+	// +optional
+	CurrentRevision int64 `json:"currentRevision,omitempty"`
+	// ExternalAddresses the IPs or hostnames of the load balancer in loadbalancer mode
+	// +optional
+	ExternalAddresses []string `json:"externalAddresses,omitempty"`
+	// Schedule the replicas applied by spec.schedule and its next change
+	// +optional
+	Schedule *ScheduleStatus `json:"schedule,omitempty"`
+	// SuspendedReplicas the replicas of Deployment when spec.suspend was set, the autoscaling restarts from them
+	// +optional
+	SuspendedReplicas *int32 `json:"suspendedReplicas,omitempty"`
+	// Build the state of image build from source
+	// +optional
+	Build *BuildStatus `json:"build,omitempty"`
+	// QOSClass the QoS class of the instance pods resulting from spec.resources
+	// +optional
+	QOSClass corev1.PodQOSClass `json:"qosClass,omitempty"`
+	// EnvFromHash the hash of data of the Secrets and ConfigMaps in spec.envFrom
+	// +optional
+	EnvFromHash string `json:"envFromHash,omitempty"`
+	// Rollout the progress of spec.rollout
+	// +optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+}
+
+// ScheduleStatus defines the observed state of schedule
+type ScheduleStatus struct {
+	// Replicas the replicas applied by spec.schedule or the schedule override annotation
+	Replicas int32 `json:"replicas"`
+	// Override true when the replicas come from the schedule override annotation
+	// +optional
+	Override bool `json:"override,omitempty"`
+	// NextTime the time of next change of spec.schedule
+	// +optional
+	NextTime *metav1.Time `json:"nextTime,omitempty"`
+	// NextReplicas the replicas applied at nextTime
+	// +optional
+	NextReplicas *int32 `json:"nextReplicas,omitempty"`
+}
+
+// RolloutStatus defines the observed state of rollout
+type RolloutStatus struct {
+	// Revision the hash of pod template being rolled out
+	Revision string `json:"revision"`
+	// Phase Progressing | Paused | Promoted | Promoting | Completed | Aborted
+	Phase string `json:"phase"`
+	// Step the index of current step of spec.rollout.canary.steps
+	// +optional
+	Step int32 `json:"step,omitempty"`
+	// Weight the percentage of traffic sent to the new pod template
+	// +optional
+	Weight int32 `json:"weight,omitempty"`
+	// StepStartedAt the time current step started
+	// +optional
+	StepStartedAt *metav1.Time `json:"stepStartedAt,omitempty"`
+	// Color the color of parallel Deployment of blue/green, blue or green
+	// +optional
+	Color string `json:"color,omitempty"`
+	// ScaleDownAt the time the previous pods of blue/green are replaced after the switch
+	// +optional
+	ScaleDownAt *metav1.Time `json:"scaleDownAt,omitempty"`
+}
+
+// BuildStatus defines the observed state of image build
+type BuildStatus struct {
+	// JobName the Job runs the build of spec.build
+	JobName string `json:"jobName"`
+	// Hash the hash of spec.build the Job was created from
+	Hash string `json:"hash"`
+	// Digest the digest of built image. It is empty until the build succeeds
+	// +optional
+	Digest string `json:"digest,omitempty"`
+	// Image the built image referenced by digest, it is used by deployment
+	// +optional
+	Image string `json:"image,omitempty"`
+	// Failed the build of Hash is failed, the Job is not created again until spec.build changes
+	// +optional
+	Failed bool `json:"failed,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -17,9 +17,21 @@ limitations under the License.
 package v1
 
 import (
+	"fmt"
+	"net"
+	"path"
+	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -27,14 +39,46 @@ import (
 )
 
 const (
-	ServiceNodePort = "nodeport"
-	ServiceIngress  = "ingress"
+	ServiceNodePort     = "nodeport"
+	ServiceIngress      = "ingress"
+	ServiceLoadBalancer = "loadbalancer"
+	ServiceGateway      = "gateway"
+	ServiceInternal     = "internal"
+	ServiceNone         = "none"
 )
 
+const (
+	BuilderKaniko   = "kaniko"
+	BuilderBuildkit = "buildkit"
+)
+
+const (
+	StorageDeletePolicyDelete = "Delete"
+	StorageDeletePolicyRetain = "Retain"
+)
+
+// DefaultTargetCPUUtilizationPercentage the CPU target of spec.autoscaling when no target is set
+var DefaultTargetCPUUtilizationPercentage int32 = 80
+
+// DefaultRevisionHistoryLimit how many applied specs are kept when spec.revisionHistoryLimit is not set
+var DefaultRevisionHistoryLimit int32 = 10
+
+// DefaultScaleDownDelay how long blue/green keeps the previous pods after the switch by default
+var DefaultScaleDownDelay = 30 * time.Second
+
+// MaxConfigFilesSize the data of a ConfigMap can not be larger than 1MiB
+const MaxConfigFilesSize = 1024 * 1024
+
 // log is for logging in this package.
 var singledeploymentlog = logf.Log.WithName("singledeployment-resource")
 
 func (r *SingleDeployment) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	namespaceReader = mgr.GetAPIReader()
+	routeReader = mgr.GetAPIReader()
+	if err := setupRBACWebhookWithManager(mgr); err != nil {
+		return err
+	}
+
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -54,8 +98,101 @@ func (r *SingleDeployment) Default() {
 		r.Spec.Replicas = 1
 	}
 
-	if r.Spec.Expose.ServicePort == 0 {
-		r.Spec.Expose.ServicePort = r.Spec.Port
+	if r.Spec.Autoscaling != nil {
+		if r.Spec.Autoscaling.MinReplicas == nil {
+			minReplicas := r.Spec.Replicas
+			r.Spec.Autoscaling.MinReplicas = &minReplicas
+		}
+		if r.Spec.Autoscaling.TargetCPUUtilizationPercentage == nil &&
+			r.Spec.Autoscaling.TargetMemoryUtilizationPercentage == nil &&
+			len(r.Spec.Autoscaling.Metrics) == 0 {
+			cpu := DefaultTargetCPUUtilizationPercentage
+			r.Spec.Autoscaling.TargetCPUUtilizationPercentage = &cpu
+		}
+	}
+
+	if len(r.Spec.Ports) == 0 {
+		if r.Spec.Expose.ServicePort == 0 {
+			r.Spec.Expose.ServicePort = r.Spec.Port
+		}
+	} else {
+		// The legacy service port is defaulted from spec.port, it is replaced by spec.ports
+		r.Spec.Expose.ServicePort = 0
+		portFound := false
+		for i := range r.Spec.Ports {
+			defaultPort(&r.Spec.Ports[i], len(r.Spec.Ports))
+			if r.Spec.Ports[i].ContainerPort == r.Spec.Port && r.Spec.Ports[i].Protocol == corev1.ProtocolTCP {
+				portFound = true
+			}
+		}
+		// spec.port is the port of probes by default, keep it one of the TCP ports of spec.ports. It is empty
+		// when there is no TCP port, the probes can not connect to UDP ports
+		if !portFound {
+			r.Spec.Port = 0
+			if i := FirstTCPPort(r.Spec.Ports); i >= 0 {
+				r.Spec.Port = r.Spec.Ports[i].ContainerPort
+			}
+		}
+	}
+	r.defaultGateway()
+	r.defaultTLS()
+	r.defaultRoutes()
+	defaultResources(&r.Spec.Resources, r.Namespace)
+	for i := range r.Spec.Storage {
+		if r.Spec.Storage[i].DeletePolicy == "" {
+			r.Spec.Storage[i].DeletePolicy = StorageDeletePolicyDelete
+		}
+		if len(r.Spec.Storage[i].AccessModes) == 0 {
+			r.Spec.Storage[i].AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+		}
+	}
+	if r.Spec.RegistryAuth != nil {
+		if r.Spec.RegistryAuth.UsernameKey == "" {
+			r.Spec.RegistryAuth.UsernameKey = corev1.BasicAuthUsernameKey
+		}
+		if r.Spec.RegistryAuth.PasswordKey == "" {
+			r.Spec.RegistryAuth.PasswordKey = corev1.BasicAuthPasswordKey
+		}
+	}
+	if r.Spec.ServiceAccount != nil &&
+		r.Spec.ServiceAccount.Create &&
+		r.Spec.ServiceAccount.Name == "" {
+		r.Spec.ServiceAccount.Name = r.Name
+	}
+	defaultSecurityContext(&r.Spec.SecurityContext)
+	if r.Spec.Rollout != nil &&
+		r.Spec.Rollout.Canary != nil &&
+		r.Spec.Rollout.Canary.Replicas == nil {
+		replicas := int32(1)
+		r.Spec.Rollout.Canary.Replicas = &replicas
+	}
+	if r.Spec.Rollout != nil && r.Spec.Rollout.BlueGreen != nil {
+		if r.Spec.Rollout.BlueGreen.ScaleDownDelay == nil {
+			r.Spec.Rollout.BlueGreen.ScaleDownDelay = &metav1.Duration{Duration: DefaultScaleDownDelay}
+		}
+		if r.Spec.Rollout.BlueGreen.PreviewHost == "" &&
+			strings.ToLower(r.Spec.Expose.Mode) == ServiceIngress &&
+			r.Spec.Expose.IngressDomain != "" {
+			r.Spec.Rollout.BlueGreen.PreviewHost = "preview." + r.Spec.Expose.IngressDomain
+		}
+	}
+	if r.Spec.RevisionHistoryLimit == nil {
+		limit := DefaultRevisionHistoryLimit
+		r.Spec.RevisionHistoryLimit = &limit
+	}
+	for i := range r.Spec.Sidecars {
+		if r.Spec.Sidecars[i].InheritEnvironments == nil {
+			inherit := true
+			r.Spec.Sidecars[i].InheritEnvironments = &inherit
+		}
+	}
+	if r.Spec.Build != nil {
+		if r.Spec.Build.Builder == "" {
+			r.Spec.Build.Builder = BuilderKaniko
+		}
+		if r.Spec.Build.Dockerfile == "" {
+			r.Spec.Build.Dockerfile = "Dockerfile"
+		}
 	}
 }
 
@@ -68,14 +205,33 @@ var _ webhook.Validator = &SingleDeployment{}
 func (r *SingleDeployment) ValidateCreate() error {
 	singledeploymentlog.Info("validate create", "name", r.Name)
 
-	return r.validateCreateAndUpdate()
+	if err := r.validateCreateAndUpdate(); err != nil {
+		return err
+	}
+
+	if errs := r.validateIngressCollision(nil, field.NewPath("spec", "expose")); len(errs) != 0 {
+		return errs.ToAggregate()
+	}
+	return nil
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (r *SingleDeployment) ValidateUpdate(_ runtime.Object) error {
+func (r *SingleDeployment) ValidateUpdate(old runtime.Object) error {
 	singledeploymentlog.Info("validate update", "name", r.Name)
 
-	return r.validateCreateAndUpdate()
+	if err := r.validateCreateAndUpdate(); err != nil {
+		return err
+	}
+
+	errs := field.ErrorList{}
+	if oldSD, ok := old.(*SingleDeployment); ok {
+		errs = append(errs, r.validateStorageUpdate(oldSD, field.NewPath("spec", "storage"))...)
+		errs = append(errs, r.validateIngressCollision(oldSD, field.NewPath("spec", "expose"))...)
+	}
+	if len(errs) != 0 {
+		return errs.ToAggregate()
+	}
+	return nil
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -89,13 +245,19 @@ func (r *SingleDeployment) ValidateDelete() error {
 func (r *SingleDeployment) validateCreateAndUpdate() error {
 	errs := field.ErrorList{}
 	exposePath := field.NewPath("spec", "expose")
-	if strings.ToLower(r.Spec.Expose.Mode) != ServiceNodePort &&
-		strings.ToLower(r.Spec.Expose.Mode) != ServiceIngress {
+	switch strings.ToLower(r.Spec.Expose.Mode) {
+	case ServiceNodePort, ServiceIngress, ServiceLoadBalancer, ServiceGateway, ServiceInternal, ServiceNone:
+	default:
 		errs = append(errs,
-			field.NotSupported(exposePath.Child("mode"), r.Spec.Expose.Mode, []string{ServiceIngress, ServiceNodePort}))
+			field.NotSupported(exposePath.Child("mode"), r.Spec.Expose.Mode, []string{ServiceIngress, ServiceNodePort, ServiceLoadBalancer, ServiceGateway, ServiceInternal, ServiceNone}))
 	}
+	errs = append(errs, r.validateLoadBalancer(exposePath)...)
+	errs = append(errs, r.validateGateway(exposePath.Child("gateway"))...)
+	errs = append(errs, r.validateTLS(exposePath.Child("tls"))...)
+	errs = append(errs, r.validateRoutes(exposePath.Child("routes"))...)
 
-	if strings.ToLower(r.Spec.Expose.Mode) == ServiceNodePort &&
+	if len(r.Spec.Ports) == 0 &&
+		strings.ToLower(r.Spec.Expose.Mode) == ServiceNodePort &&
 		(r.Spec.Expose.NodePort == 0 ||
 			r.Spec.Expose.NodePort > 32767 ||
 			r.Spec.Expose.NodePort < 30000) {
@@ -104,9 +266,67 @@ func (r *SingleDeployment) validateCreateAndUpdate() error {
 	}
 
 	if strings.ToLower(r.Spec.Expose.Mode) == ServiceIngress &&
-		r.Spec.Expose.IngressDomain == "" {
+		r.Spec.Expose.IngressDomain == "" &&
+		len(r.Spec.Expose.Routes) == 0 {
 		errs = append(errs,
-			field.Invalid(exposePath.Child("ingressDomain"), r.Spec.Expose.NodePort, "If spec.expose.mode is `ingress`, the `spec.expose.ingressDomain` must not be empty "))
+			field.Invalid(exposePath.Child("ingressDomain"), r.Spec.Expose.NodePort, "If spec.expose.mode is `ingress`, the `spec.expose.ingressDomain` or `spec.expose.routes` must not be empty "))
+	}
+
+	specPath := field.NewPath("spec")
+	if len(r.Spec.Ports) == 0 {
+		if r.Spec.Port <= 0 || r.Spec.Port > 65535 {
+			errs = append(errs,
+				field.Invalid(specPath.Child("port"), r.Spec.Port, "If `spec.ports` is empty, the `spec.port` must be in 1-65535"))
+		}
+	} else {
+		errs = append(errs, r.validatePorts(specPath.Child("ports"))...)
+	}
+	if r.Spec.Shell && strings.TrimSpace(r.Spec.StartCmd) == "" {
+		errs = append(errs,
+			field.Required(specPath.Child("startCmd"), "If spec.shell is true, the `spec.startCmd` must not be empty"))
+	}
+	if !r.Spec.Shell && r.Spec.StartCmd != "" {
+		if _, _, err := r.Spec.ContainerCommand(r.Name); err != nil {
+			errs = append(errs,
+				field.Invalid(specPath.Child("startCmd"), r.Spec.StartCmd, err.Error()))
+		}
+	}
+
+	if r.Spec.Image == "" && r.Spec.Build == nil {
+		errs = append(errs,
+			field.Required(specPath.Child("image"), "One of `spec.image` and `spec.build` must not be empty"))
+	}
+	if r.Spec.Image == "" && r.Spec.Build != nil {
+		errs = append(errs, r.validateBuild(specPath.Child("build"))...)
+	}
+
+	errs = append(errs, validateResources(&r.Spec.Resources, specPath.Child("resources"))...)
+	errs = append(errs, r.validateAutoscaling(specPath.Child("autoscaling"))...)
+	errs = append(errs, r.validateDisruptionBudget(specPath.Child("disruptionBudget"))...)
+	errs = append(errs, r.validateSchedule(specPath.Child("schedule"))...)
+	errs = append(errs, r.validateRollout(specPath.Child("rollout"))...)
+	if r.Spec.RevisionHistoryLimit != nil && *r.Spec.RevisionHistoryLimit < 1 {
+		errs = append(errs,
+			field.Invalid(specPath.Child("revisionHistoryLimit"), *r.Spec.RevisionHistoryLimit, "It must be greater than 0"))
+	}
+	if r.Spec.RollbackTo != nil && *r.Spec.RollbackTo < 1 {
+		errs = append(errs,
+			field.Invalid(specPath.Child("rollbackTo"), *r.Spec.RollbackTo, "It must be greater than 0"))
+	}
+	errs = append(errs, r.validateStorage(specPath.Child("storage"))...)
+	errs = append(errs, r.validateScheduling(specPath.Child("scheduling"))...)
+	errs = append(errs, r.validateContainers(specPath)...)
+	errs = append(errs, r.validateServiceAccount(specPath.Child("serviceAccount"))...)
+	errs = append(errs, r.validateSecurityContext(specPath)...)
+	errs = append(errs, r.validateImagePullSecrets(specPath)...)
+	errs = append(errs, r.validateConfigFiles(specPath.Child("configFiles"))...)
+	errs = append(errs, r.validateEnvFrom(specPath.Child("envFrom"))...)
+	if r.Spec.HealthCheck != nil {
+		healthCheckPath := specPath.Child("healthCheck")
+		hasTCPPort := FirstTCPPort(r.Spec.GetPorts()) >= 0
+		errs = append(errs, validateProbe(r.Spec.HealthCheck.Liveness, healthCheckPath.Child("liveness"), true, hasTCPPort)...)
+		errs = append(errs, validateProbe(r.Spec.HealthCheck.Readiness, healthCheckPath.Child("readiness"), false, hasTCPPort)...)
+		errs = append(errs, validateProbe(r.Spec.HealthCheck.Startup, healthCheckPath.Child("startup"), true, hasTCPPort)...)
 	}
 
 	if len(errs) != 0 {
@@ -115,3 +335,823 @@ func (r *SingleDeployment) validateCreateAndUpdate() error {
 
 	return nil
 }
+
+func (r *SingleDeployment) validateBuild(buildPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	build := r.Spec.Build
+
+	sourcePath := buildPath.Child("source")
+	sources := 0
+	if build.Source.Git != nil {
+		sources++
+		if build.Source.Git.URL == "" {
+			errs = append(errs,
+				field.Required(sourcePath.Child("git", "url"), "The url of git repository must not be empty"))
+		}
+	}
+	if build.Source.ConfigMap != nil {
+		sources++
+		if build.Source.ConfigMap.Name == "" {
+			errs = append(errs,
+				field.Required(sourcePath.Child("configMap", "name"), "The name of ConfigMap must not be empty"))
+		}
+	}
+	if build.Source.PersistentVolumeClaim != nil {
+		sources++
+		if build.Source.PersistentVolumeClaim.ClaimName == "" {
+			errs = append(errs,
+				field.Required(sourcePath.Child("persistentVolumeClaim", "claimName"), "The name of PersistentVolumeClaim must not be empty"))
+		}
+	}
+	if sources != 1 {
+		errs = append(errs,
+			field.Invalid(sourcePath, build.Source, "Exactly one of `git`, `configMap` and `persistentVolumeClaim` must be set"))
+	}
+
+	if path.IsAbs(build.ContextDir) || strings.HasPrefix(path.Clean(build.ContextDir), "..") {
+		errs = append(errs,
+			field.Invalid(buildPath.Child("contextDir"), build.ContextDir, "It must be a relative path inside the source"))
+	}
+	if path.IsAbs(build.Dockerfile) || strings.HasPrefix(path.Clean(build.Dockerfile), "..") {
+		errs = append(errs,
+			field.Invalid(buildPath.Child("dockerfile"), build.Dockerfile, "It must be a relative path inside the build context"))
+	}
+
+	lastSegment := build.Registry[strings.LastIndex(build.Registry, "/")+1:]
+	if build.Registry == "" {
+		errs = append(errs,
+			field.Required(buildPath.Child("registry"), "The image repository the built image is pushed to must not be empty"))
+	} else if strings.Contains(build.Registry, "@") || strings.Contains(lastSegment, ":") {
+		errs = append(errs,
+			field.Invalid(buildPath.Child("registry"), build.Registry, "It must be an image repository without tag or digest"))
+	}
+
+	if build.Builder != BuilderKaniko && build.Builder != BuilderBuildkit {
+		errs = append(errs,
+			field.NotSupported(buildPath.Child("builder"), build.Builder, []string{BuilderKaniko, BuilderBuildkit}))
+	}
+
+	return errs
+}
+
+func validateResources(resources *corev1.ResourceRequirements, resourcesPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	for name, quantity := range resources.Limits {
+		if quantity.Sign() < 0 {
+			errs = append(errs,
+				field.Invalid(resourcesPath.Child("limits").Key(string(name)), quantity.String(), "It must be greater than or equal to 0"))
+		}
+	}
+	for name, quantity := range resources.Requests {
+		if quantity.Sign() < 0 {
+			errs = append(errs,
+				field.Invalid(resourcesPath.Child("requests").Key(string(name)), quantity.String(), "It must be greater than or equal to 0"))
+		}
+		if limit, ok := resources.Limits[name]; ok && quantity.Cmp(limit) > 0 {
+			errs = append(errs,
+				field.Invalid(resourcesPath.Child("requests").Key(string(name)), quantity.String(), fmt.Sprintf("It must be less than or equal to %s limit %s", name, limit.String())))
+		}
+	}
+
+	return errs
+}
+
+// validateProbe checks the probe, an empty port is the first TCP port, so it must be set when hasTCPPort is false
+func validateProbe(probe *Probe, probePath *field.Path, singleSuccess, hasTCPPort bool) field.ErrorList {
+	errs := field.ErrorList{}
+	if probe == nil {
+		return errs
+	}
+
+	handlers := 0
+	if probe.HTTPGet != nil {
+		handlers++
+		if !strings.HasPrefix(probe.HTTPGet.Path, "/") {
+			errs = append(errs,
+				field.Invalid(probePath.Child("httpGet", "path"), probe.HTTPGet.Path, "It must be an absolute path starting with `/`"))
+		}
+		if probe.HTTPGet.Port < 0 || probe.HTTPGet.Port > 65535 {
+			errs = append(errs,
+				field.Invalid(probePath.Child("httpGet", "port"), probe.HTTPGet.Port, "It must be in 1-65535, or empty to use the first TCP port"))
+		} else if probe.HTTPGet.Port == 0 && !hasTCPPort {
+			errs = append(errs,
+				field.Required(probePath.Child("httpGet", "port"), "It must be set, there is no TCP port to use"))
+		}
+		if scheme := strings.ToUpper(probe.HTTPGet.Scheme); scheme != "" && scheme != "HTTP" && scheme != "HTTPS" {
+			errs = append(errs,
+				field.NotSupported(probePath.Child("httpGet", "scheme"), probe.HTTPGet.Scheme, []string{"HTTP", "HTTPS"}))
+		}
+	}
+	if probe.TCPSocket != nil {
+		handlers++
+		if probe.TCPSocket.Port < 0 || probe.TCPSocket.Port > 65535 {
+			errs = append(errs,
+				field.Invalid(probePath.Child("tcpSocket", "port"), probe.TCPSocket.Port, "It must be in 1-65535, or empty to use the first TCP port"))
+		} else if probe.TCPSocket.Port == 0 && !hasTCPPort {
+			errs = append(errs,
+				field.Required(probePath.Child("tcpSocket", "port"), "It must be set, there is no TCP port to use"))
+		}
+	}
+	if probe.Exec != nil {
+		handlers++
+		if len(probe.Exec.Command) == 0 {
+			errs = append(errs,
+				field.Required(probePath.Child("exec", "command"), "The command of exec probe must not be empty"))
+		}
+	}
+	if handlers != 1 {
+		errs = append(errs,
+			field.Invalid(probePath, handlers, "Exactly one of `httpGet`, `tcpSocket` and `exec` must be set"))
+	}
+
+	for name, value := range map[string]int32{
+		"initialDelaySeconds": probe.InitialDelaySeconds,
+		"periodSeconds":       probe.PeriodSeconds,
+		"timeoutSeconds":      probe.TimeoutSeconds,
+		"successThreshold":    probe.SuccessThreshold,
+		"failureThreshold":    probe.FailureThreshold,
+	} {
+		if value < 0 {
+			errs = append(errs,
+				field.Invalid(probePath.Child(name), value, "It must be greater than or equal to 0"))
+		}
+	}
+	if singleSuccess && probe.SuccessThreshold > 1 {
+		errs = append(errs,
+			field.Invalid(probePath.Child("successThreshold"), probe.SuccessThreshold, "It must be 1 for liveness and startup probes"))
+	}
+
+	return errs
+}
+
+func (r *SingleDeployment) validatePorts(portsPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	mode := strings.ToLower(r.Spec.Expose.Mode)
+
+	if r.Spec.Expose.NodePort != 0 {
+		errs = append(errs,
+			field.Invalid(field.NewPath("spec", "expose", "nodePort"), r.Spec.Expose.NodePort, "It can not be used with `spec.ports`, set `spec.ports[*].nodePort` instead"))
+	}
+
+	names := map[string]bool{}
+	containerPorts := map[string]bool{}
+	servicePorts := map[string]bool{}
+	nodePorts := map[int32]bool{}
+	ingressPaths := map[string]bool{}
+	for i, port := range r.Spec.Ports {
+		portPath := portsPath.Index(i)
+		defaultPort(&port, len(r.Spec.Ports))
+
+		if port.Name == "" {
+			errs = append(errs,
+				field.Required(portPath.Child("name"), "The name of port is required when there are more than one ports"))
+		} else {
+			for _, msg := range validation.IsValidPortName(port.Name) {
+				errs = append(errs, field.Invalid(portPath.Child("name"), port.Name, msg))
+			}
+			if names[port.Name] {
+				errs = append(errs, field.Duplicate(portPath.Child("name"), port.Name))
+			}
+			names[port.Name] = true
+		}
+
+		if port.ContainerPort <= 0 || port.ContainerPort > 65535 {
+			errs = append(errs,
+				field.Invalid(portPath.Child("containerPort"), port.ContainerPort, "It must be in 1-65535"))
+		}
+		if port.ServicePort <= 0 || port.ServicePort > 65535 {
+			errs = append(errs,
+				field.Invalid(portPath.Child("servicePort"), port.ServicePort, "It must be in 1-65535"))
+		}
+		if port.Protocol != corev1.ProtocolTCP &&
+			port.Protocol != corev1.ProtocolUDP &&
+			port.Protocol != corev1.ProtocolSCTP {
+			errs = append(errs,
+				field.NotSupported(portPath.Child("protocol"), port.Protocol, []string{string(corev1.ProtocolTCP), string(corev1.ProtocolUDP), string(corev1.ProtocolSCTP)}))
+		}
+
+		containerPort := fmt.Sprintf("%d/%s", port.ContainerPort, port.Protocol)
+		if containerPorts[containerPort] {
+			errs = append(errs, field.Duplicate(portPath.Child("containerPort"), containerPort))
+		}
+		containerPorts[containerPort] = true
+		servicePort := fmt.Sprintf("%d/%s", port.ServicePort, port.Protocol)
+		if servicePorts[servicePort] {
+			errs = append(errs, field.Duplicate(portPath.Child("servicePort"), servicePort))
+		}
+		servicePorts[servicePort] = true
+
+		if port.NodePort != 0 {
+			if mode != ServiceNodePort {
+				errs = append(errs,
+					field.Invalid(portPath.Child("nodePort"), port.NodePort, "It can only be set in nodeport mode"))
+			} else if port.NodePort < 30000 || port.NodePort > 32767 {
+				errs = append(errs,
+					field.Invalid(portPath.Child("nodePort"), port.NodePort, "It must be in 30000-32767"))
+			} else if nodePorts[port.NodePort] {
+				errs = append(errs, field.Duplicate(portPath.Child("nodePort"), port.NodePort))
+			}
+			nodePorts[port.NodePort] = true
+		}
+
+		if port.IngressPath != "" {
+			if mode != ServiceIngress && mode != ServiceGateway {
+				errs = append(errs,
+					field.Invalid(portPath.Child("ingressPath"), port.IngressPath, "It can only be set in ingress and gateway modes"))
+			} else if len(r.Spec.Expose.Routes) != 0 {
+				errs = append(errs,
+					field.Invalid(portPath.Child("ingressPath"), port.IngressPath, "It can not be used with `spec.expose.routes`"))
+			} else if !strings.HasPrefix(port.IngressPath, "/") {
+				errs = append(errs,
+					field.Invalid(portPath.Child("ingressPath"), port.IngressPath, "It must be an absolute path starting with `/`"))
+			} else if port.Protocol != corev1.ProtocolTCP {
+				errs = append(errs,
+					field.Invalid(portPath.Child("ingressPath"), port.IngressPath, "Only TCP port can be exposed by ingress or gateway"))
+			} else if ingressPaths[port.IngressPath] {
+				errs = append(errs, field.Duplicate(portPath.Child("ingressPath"), port.IngressPath))
+			}
+			ingressPaths[port.IngressPath] = true
+		}
+	}
+
+	return errs
+}
+
+func (r *SingleDeployment) validateLoadBalancer(exposePath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	expose := r.Spec.Expose
+	mode := strings.ToLower(expose.Mode)
+
+	if mode != ServiceLoadBalancer {
+		if expose.LoadBalancerIP != "" {
+			errs = append(errs,
+				field.Invalid(exposePath.Child("loadBalancerIP"), expose.LoadBalancerIP, "It can only be set in loadbalancer mode"))
+		}
+		if len(expose.SourceRanges) != 0 {
+			errs = append(errs,
+				field.Invalid(exposePath.Child("sourceRanges"), expose.SourceRanges, "It can only be set in loadbalancer mode"))
+		}
+		if len(expose.Annotations) != 0 {
+			errs = append(errs,
+				field.Invalid(exposePath.Child("annotations"), expose.Annotations, "It can only be set in loadbalancer mode"))
+		}
+	}
+	if expose.ExternalTrafficPolicy != "" {
+		if mode != ServiceLoadBalancer && mode != ServiceNodePort {
+			errs = append(errs,
+				field.Invalid(exposePath.Child("externalTrafficPolicy"), expose.ExternalTrafficPolicy, "It can only be set in nodeport and loadbalancer modes"))
+		} else if expose.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyTypeCluster &&
+			expose.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyTypeLocal {
+			errs = append(errs,
+				field.NotSupported(exposePath.Child("externalTrafficPolicy"), expose.ExternalTrafficPolicy, []string{string(corev1.ServiceExternalTrafficPolicyTypeCluster), string(corev1.ServiceExternalTrafficPolicyTypeLocal)}))
+		}
+	}
+	if expose.LoadBalancerIP != "" && net.ParseIP(expose.LoadBalancerIP) == nil {
+		errs = append(errs,
+			field.Invalid(exposePath.Child("loadBalancerIP"), expose.LoadBalancerIP, "It must be an IP address"))
+	}
+	for i, cidr := range expose.SourceRanges {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			errs = append(errs,
+				field.Invalid(exposePath.Child("sourceRanges").Index(i), cidr, "It must be a CIDR, e.g. `10.0.0.0/8`"))
+		}
+	}
+	errs = append(errs, apivalidation.ValidateAnnotations(expose.Annotations, exposePath.Child("annotations"))...)
+
+	return errs
+}
+
+func (r *SingleDeployment) validateRollout(rolloutPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if r.Spec.Rollout == nil {
+		return errs
+	}
+	if r.Spec.Rollout.Canary != nil && r.Spec.Rollout.BlueGreen != nil {
+		errs = append(errs,
+			field.Invalid(rolloutPath, "", "Only one of `canary` and `blueGreen` can be set"))
+	}
+	if r.Spec.Rollout.BlueGreen != nil {
+		errs = append(errs, r.validateBlueGreen(rolloutPath.Child("blueGreen"))...)
+	}
+	if r.Spec.Rollout.Canary == nil {
+		return errs
+	}
+	canary := r.Spec.Rollout.Canary
+	canaryPath := rolloutPath.Child("canary")
+
+	// The traffic of canary is split by the canary annotations of ingress-nginx
+	if strings.ToLower(r.Spec.Expose.Mode) != ServiceIngress {
+		errs = append(errs,
+			field.Invalid(canaryPath, "", "It must be used with `spec.expose.mode` `ingress`"))
+	}
+	if canary.Replicas != nil && *canary.Replicas < 1 {
+		errs = append(errs,
+			field.Invalid(canaryPath.Child("replicas"), *canary.Replicas, "It must be greater than or equal to 1"))
+	}
+	if len(canary.Steps) == 0 {
+		errs = append(errs,
+			field.Required(canaryPath.Child("steps"), "It must have at least 1 step"))
+	}
+	for i, step := range canary.Steps {
+		stepPath := canaryPath.Child("steps").Index(i)
+		set := 0
+		if step.Weight != nil {
+			set++
+			if *step.Weight < 0 || *step.Weight > 100 {
+				errs = append(errs,
+					field.Invalid(stepPath.Child("weight"), *step.Weight, "It must be in 0-100"))
+			}
+		}
+		if step.Pause != nil {
+			set++
+			if step.Pause.Duration <= 0 {
+				errs = append(errs,
+					field.Invalid(stepPath.Child("pause"), step.Pause.Duration.String(), "It must be greater than 0"))
+			}
+		}
+		if step.Approval {
+			set++
+		}
+		if set != 1 {
+			errs = append(errs,
+				field.Invalid(stepPath, "", "Exactly one of `weight`, `pause` and `approval` must be set"))
+		}
+	}
+
+	return errs
+}
+
+func (r *SingleDeployment) validateBlueGreen(blueGreenPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	blueGreen := r.Spec.Rollout.BlueGreen
+
+	// The traffic is switched by the selector of Service
+	if strings.ToLower(r.Spec.Expose.Mode) == ServiceNone {
+		errs = append(errs,
+			field.Invalid(blueGreenPath, "", "It can not be used with `spec.expose.mode` `none`"))
+	}
+	if blueGreen.ScaleDownDelay != nil && blueGreen.ScaleDownDelay.Duration < 0 {
+		errs = append(errs,
+			field.Invalid(blueGreenPath.Child("scaleDownDelay"), blueGreen.ScaleDownDelay.Duration.String(), "It must be greater than or equal to 0"))
+	}
+	if blueGreen.PreviewHost != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(blueGreen.PreviewHost) {
+			errs = append(errs, field.Invalid(blueGreenPath.Child("previewHost"), blueGreen.PreviewHost, msg))
+		}
+		if blueGreen.PreviewHost == r.Spec.Expose.IngressDomain {
+			errs = append(errs,
+				field.Invalid(blueGreenPath.Child("previewHost"), blueGreen.PreviewHost, "It must not be `spec.expose.ingressDomain`"))
+		}
+	}
+
+	return errs
+}
+
+func (r *SingleDeployment) validateAutoscaling(autoscalingPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	autoscaling := r.Spec.Autoscaling
+	if autoscaling == nil {
+		return errs
+	}
+
+	if autoscaling.MaxReplicas < 1 {
+		errs = append(errs,
+			field.Invalid(autoscalingPath.Child("maxReplicas"), autoscaling.MaxReplicas, "It must be greater than or equal to 1"))
+	}
+	if autoscaling.MinReplicas != nil &&
+		(*autoscaling.MinReplicas < 1 || *autoscaling.MinReplicas > autoscaling.MaxReplicas) {
+		errs = append(errs,
+			field.Invalid(autoscalingPath.Child("minReplicas"), *autoscaling.MinReplicas, "It must be in 1-`spec.autoscaling.maxReplicas`"))
+	}
+
+	// The utilization is a percentage of the requests, so the requests must be set
+	if autoscaling.TargetCPUUtilizationPercentage != nil {
+		if *autoscaling.TargetCPUUtilizationPercentage < 1 {
+			errs = append(errs,
+				field.Invalid(autoscalingPath.Child("targetCPUUtilizationPercentage"), *autoscaling.TargetCPUUtilizationPercentage, "It must be greater than 0"))
+		}
+		if _, ok := r.Spec.Resources.Requests[corev1.ResourceCPU]; !ok {
+			errs = append(errs,
+				field.Required(field.NewPath("spec", "resources", "requests", "cpu"), "It must be set when `spec.autoscaling.targetCPUUtilizationPercentage` is set"))
+		}
+	}
+	if autoscaling.TargetMemoryUtilizationPercentage != nil {
+		if *autoscaling.TargetMemoryUtilizationPercentage < 1 {
+			errs = append(errs,
+				field.Invalid(autoscalingPath.Child("targetMemoryUtilizationPercentage"), *autoscaling.TargetMemoryUtilizationPercentage, "It must be greater than 0"))
+		}
+		if _, ok := r.Spec.Resources.Requests[corev1.ResourceMemory]; !ok {
+			errs = append(errs,
+				field.Required(field.NewPath("spec", "resources", "requests", "memory"), "It must be set when `spec.autoscaling.targetMemoryUtilizationPercentage` is set"))
+		}
+	}
+	for i, metric := range autoscaling.Metrics {
+		if metric.Type == "" {
+			errs = append(errs,
+				field.Required(autoscalingPath.Child("metrics").Index(i).Child("type"), "The type of metric must not be empty"))
+		}
+	}
+
+	return errs
+}
+
+func (r *SingleDeployment) validateDisruptionBudget(disruptionBudgetPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	budget := r.Spec.DisruptionBudget
+	if budget == nil {
+		return errs
+	}
+
+	if budget.MinAvailable != nil && budget.MaxUnavailable != nil {
+		errs = append(errs,
+			field.Forbidden(disruptionBudgetPath, "Only one of `minAvailable` and `maxUnavailable` can be set"))
+	}
+	errs = append(errs, validateIntOrPercent(budget.MinAvailable, disruptionBudgetPath.Child("minAvailable"))...)
+	errs = append(errs, validateIntOrPercent(budget.MaxUnavailable, disruptionBudgetPath.Child("maxUnavailable"))...)
+
+	return errs
+}
+
+func validateIntOrPercent(value *intstr.IntOrString, valuePath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if value == nil {
+		return errs
+	}
+
+	if value.Type == intstr.Int {
+		if value.IntVal < 0 {
+			errs = append(errs,
+				field.Invalid(valuePath, value.IntVal, "It must be greater than or equal to 0"))
+		}
+		return errs
+	}
+	percent, err := strconv.Atoi(strings.TrimSuffix(value.StrVal, "%"))
+	if !strings.HasSuffix(value.StrVal, "%") || err != nil || percent < 0 || percent > 100 {
+		errs = append(errs,
+			field.Invalid(valuePath, value.StrVal, "It must be an integer or a percentage in 0%-100%"))
+	}
+
+	return errs
+}
+
+// validateContainers checks the sidecars and init containers do not collide with the instance container
+func (r *SingleDeployment) validateContainers(specPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	// The instance container is named after the instance
+	names := map[string]bool{r.Name: true}
+	ports := map[int32]bool{}
+	if len(r.Spec.Ports) == 0 {
+		ports[r.Spec.Port] = true
+	}
+	for _, port := range r.Spec.Ports {
+		ports[port.ContainerPort] = true
+	}
+
+	validateContainer := func(c *corev1.Container, containerPath *field.Path) {
+		if c.Name == "" {
+			errs = append(errs, field.Required(containerPath.Child("name"), "The name of container must not be empty"))
+		} else if names[c.Name] {
+			errs = append(errs, field.Duplicate(containerPath.Child("name"), c.Name))
+		} else {
+			for _, msg := range validation.IsDNS1123Label(c.Name) {
+				errs = append(errs, field.Invalid(containerPath.Child("name"), c.Name, msg))
+			}
+		}
+		names[c.Name] = true
+
+		if c.Image == "" {
+			errs = append(errs, field.Required(containerPath.Child("image"), "The image of container must not be empty"))
+		}
+	}
+
+	for i := range r.Spec.Sidecars {
+		sidecarPath := specPath.Child("sidecars").Index(i)
+		validateContainer(&r.Spec.Sidecars[i].Container, sidecarPath)
+		// The containers of a pod share the network namespace
+		for j, port := range r.Spec.Sidecars[i].Ports {
+			if ports[port.ContainerPort] {
+				errs = append(errs,
+					field.Invalid(sidecarPath.Child("ports").Index(j).Child("containerPort"), port.ContainerPort, "It must not be used by another container"))
+			}
+			ports[port.ContainerPort] = true
+		}
+	}
+	for i := range r.Spec.InitContainers {
+		validateContainer(&r.Spec.InitContainers[i], specPath.Child("initContainers").Index(i))
+	}
+
+	return errs
+}
+
+func (r *SingleDeployment) validateImagePullSecrets(specPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	for i, secret := range r.Spec.ImagePullSecrets {
+		for _, msg := range validation.IsDNS1123Subdomain(secret.Name) {
+			errs = append(errs, field.Invalid(specPath.Child("imagePullSecrets").Index(i).Child("name"), secret.Name, msg))
+		}
+	}
+
+	auth := r.Spec.RegistryAuth
+	if auth == nil {
+		return errs
+	}
+	authPath := specPath.Child("registryAuth")
+	if strings.TrimSpace(auth.Server) == "" {
+		errs = append(errs,
+			field.Required(authPath.Child("server"), "It must be a registry host, e.g. registry.example.com"))
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(auth.SecretName) {
+		errs = append(errs, field.Invalid(authPath.Child("secretName"), auth.SecretName, msg))
+	}
+	for _, key := range []struct{ name, value string }{{"usernameKey", auth.UsernameKey}, {"passwordKey", auth.PasswordKey}} {
+		if key.value == "" {
+			continue
+		}
+		for _, msg := range validation.IsConfigMapKey(key.value) {
+			errs = append(errs, field.Invalid(authPath.Child(key.name), key.value, msg))
+		}
+	}
+
+	return errs
+}
+
+func (r *SingleDeployment) validateServiceAccount(serviceAccountPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	serviceAccount := r.Spec.ServiceAccount
+	if serviceAccount == nil {
+		return errs
+	}
+
+	if serviceAccount.Name == "" {
+		if !serviceAccount.Create {
+			errs = append(errs,
+				field.Required(serviceAccountPath.Child("name"), "It must not be empty when `spec.serviceAccount.create` is false"))
+		}
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(serviceAccount.Name) {
+			errs = append(errs, field.Invalid(serviceAccountPath.Child("name"), serviceAccount.Name, msg))
+		}
+	}
+
+	rulesPath := serviceAccountPath.Child("rules")
+	if !serviceAccount.Create && len(serviceAccount.Rules) != 0 {
+		errs = append(errs,
+			field.Forbidden(rulesPath, "It can only be set when `spec.serviceAccount.create` is true"))
+	}
+	for i, rule := range serviceAccount.Rules {
+		rulePath := rulesPath.Index(i)
+		if len(rule.Verbs) == 0 {
+			errs = append(errs, field.Required(rulePath.Child("verbs"), "The verbs of rule must not be empty"))
+		}
+		if len(rule.Resources) == 0 {
+			errs = append(errs, field.Required(rulePath.Child("resources"), "The resources of rule must not be empty"))
+		}
+		if len(rule.APIGroups) == 0 {
+			errs = append(errs, field.Required(rulePath.Child("apiGroups"), "The apiGroups of rule must not be empty, use \"\" for the core group"))
+		}
+		if len(rule.NonResourceURLs) != 0 {
+			errs = append(errs, field.Forbidden(rulePath.Child("nonResourceURLs"), "It can not be granted by a Role"))
+		}
+	}
+
+	return errs
+}
+
+func (r *SingleDeployment) validateScheduling(schedulingPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	scheduling := r.Spec.Scheduling
+	if scheduling == nil {
+		return errs
+	}
+
+	errs = append(errs, metav1validation.ValidateLabels(scheduling.NodeSelector, schedulingPath.Child("nodeSelector"))...)
+
+	for i, toleration := range scheduling.Tolerations {
+		errs = append(errs, validateToleration(&toleration, schedulingPath.Child("tolerations").Index(i))...)
+	}
+
+	if scheduling.Affinity != nil && scheduling.Affinity.NodeAffinity != nil {
+		nodeAffinityPath := schedulingPath.Child("affinity", "nodeAffinity")
+		if required := scheduling.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution; required != nil {
+			termsPath := nodeAffinityPath.Child("requiredDuringSchedulingIgnoredDuringExecution", "nodeSelectorTerms")
+			for i := range required.NodeSelectorTerms {
+				errs = append(errs, validateNodeSelectorTerm(&required.NodeSelectorTerms[i], termsPath.Index(i))...)
+			}
+		}
+		preferredPath := nodeAffinityPath.Child("preferredDuringSchedulingIgnoredDuringExecution")
+		for i := range scheduling.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+			errs = append(errs, validateNodeSelectorTerm(
+				&scheduling.Affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution[i].Preference,
+				preferredPath.Index(i).Child("preference"))...)
+		}
+	}
+
+	return errs
+}
+
+func validateToleration(toleration *corev1.Toleration, tolerationPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if toleration.Key != "" {
+		for _, msg := range validation.IsQualifiedName(toleration.Key) {
+			errs = append(errs, field.Invalid(tolerationPath.Child("key"), toleration.Key, msg))
+		}
+	}
+	switch toleration.Operator {
+	case corev1.TolerationOpEqual, "":
+		if toleration.Key == "" {
+			errs = append(errs,
+				field.Invalid(tolerationPath.Child("operator"), toleration.Operator, "It must be `Exists` when `key` is empty"))
+		}
+	case corev1.TolerationOpExists:
+		if toleration.Value != "" {
+			errs = append(errs,
+				field.Invalid(tolerationPath.Child("value"), toleration.Value, "It must be empty when `operator` is `Exists`"))
+		}
+	default:
+		errs = append(errs,
+			field.NotSupported(tolerationPath.Child("operator"), toleration.Operator, []string{string(corev1.TolerationOpEqual), string(corev1.TolerationOpExists)}))
+	}
+	if toleration.Effect != "" &&
+		toleration.Effect != corev1.TaintEffectNoSchedule &&
+		toleration.Effect != corev1.TaintEffectPreferNoSchedule &&
+		toleration.Effect != corev1.TaintEffectNoExecute {
+		errs = append(errs,
+			field.NotSupported(tolerationPath.Child("effect"), toleration.Effect, []string{string(corev1.TaintEffectNoSchedule), string(corev1.TaintEffectPreferNoSchedule), string(corev1.TaintEffectNoExecute)}))
+	}
+
+	return errs
+}
+
+func validateNodeSelectorTerm(term *corev1.NodeSelectorTerm, termPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	for i, requirement := range term.MatchExpressions {
+		for _, msg := range validation.IsQualifiedName(requirement.Key) {
+			errs = append(errs, field.Invalid(termPath.Child("matchExpressions").Index(i).Child("key"), requirement.Key, msg))
+		}
+	}
+
+	return errs
+}
+
+func (r *SingleDeployment) validateStorage(storagePath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	names := map[string]bool{}
+	mountPaths := map[string]bool{}
+	for i, storage := range r.Spec.Storage {
+		itemPath := storagePath.Index(i)
+
+		// The volume is named storage-<name>, so it must be a DNS label too
+		if storage.Name == "" {
+			errs = append(errs, field.Required(itemPath.Child("name"), "The name of storage must not be empty"))
+		} else if names[storage.Name] {
+			errs = append(errs, field.Duplicate(itemPath.Child("name"), storage.Name))
+		} else {
+			for _, msg := range validation.IsDNS1123Label("storage-" + storage.Name) {
+				errs = append(errs, field.Invalid(itemPath.Child("name"), storage.Name, msg))
+			}
+		}
+		names[storage.Name] = true
+
+		if storage.Size.Sign() <= 0 {
+			errs = append(errs,
+				field.Invalid(itemPath.Child("size"), storage.Size.String(), "It must be greater than 0"))
+		}
+
+		if !path.IsAbs(storage.MountPath) {
+			errs = append(errs,
+				field.Invalid(itemPath.Child("mountPath"), storage.MountPath, "It must be an absolute path"))
+		} else if mountPaths[path.Clean(storage.MountPath)] {
+			errs = append(errs, field.Duplicate(itemPath.Child("mountPath"), storage.MountPath))
+		}
+		mountPaths[path.Clean(storage.MountPath)] = true
+
+		for j, mode := range storage.AccessModes {
+			if mode != corev1.ReadWriteOnce &&
+				mode != corev1.ReadOnlyMany &&
+				mode != corev1.ReadWriteMany &&
+				mode != corev1.ReadWriteOncePod {
+				errs = append(errs,
+					field.NotSupported(itemPath.Child("accessModes").Index(j), mode, []string{string(corev1.ReadWriteOnce), string(corev1.ReadOnlyMany), string(corev1.ReadWriteMany), string(corev1.ReadWriteOncePod)}))
+			}
+		}
+
+		if storage.DeletePolicy != StorageDeletePolicyDelete &&
+			storage.DeletePolicy != StorageDeletePolicyRetain {
+			errs = append(errs,
+				field.NotSupported(itemPath.Child("deletePolicy"), storage.DeletePolicy, []string{StorageDeletePolicyDelete, StorageDeletePolicyRetain}))
+		}
+	}
+
+	return errs
+}
+
+func (r *SingleDeployment) validateConfigFiles(configFilesPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	mountPaths := map[string]bool{}
+	for i := range r.Spec.Storage {
+		mountPaths[path.Clean(r.Spec.Storage[i].MountPath)] = true
+	}
+	keys := map[string]bool{}
+	size := 0
+	for i := range r.Spec.ConfigFiles {
+		file := &r.Spec.ConfigFiles[i]
+		pathPath := configFilesPath.Index(i).Child("path")
+		size += len(file.Content)
+
+		if !path.IsAbs(file.Path) || strings.HasSuffix(file.Path, "/") {
+			errs = append(errs,
+				field.Invalid(pathPath, file.Path, "It must be an absolute path of file"))
+			continue
+		}
+		if mountPaths[path.Clean(file.Path)] {
+			errs = append(errs,
+				field.Invalid(pathPath, file.Path, "It must not be the mount path of a storage"))
+		}
+		// The files are stored in one ConfigMap, keyed by their paths
+		key := file.Key()
+		if keys[key] {
+			errs = append(errs, field.Duplicate(pathPath, file.Path))
+		} else {
+			for _, msg := range validation.IsConfigMapKey(key) {
+				errs = append(errs, field.Invalid(pathPath, file.Path, msg))
+			}
+		}
+		keys[key] = true
+	}
+	if size > MaxConfigFilesSize {
+		errs = append(errs,
+			field.TooLong(configFilesPath, size, MaxConfigFilesSize))
+	}
+
+	return errs
+}
+
+func (r *SingleDeployment) validateEnvFrom(envFromPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	for i, source := range r.Spec.EnvFrom {
+		itemPath := envFromPath.Index(i)
+		if source.Prefix != "" {
+			for _, msg := range validation.IsEnvVarName(source.Prefix) {
+				errs = append(errs, field.Invalid(itemPath.Child("prefix"), source.Prefix, msg))
+			}
+		}
+
+		switch {
+		case source.ConfigMapRef != nil && source.SecretRef != nil:
+			errs = append(errs,
+				field.Forbidden(itemPath, "Only one of `configMapRef` and `secretRef` can be set"))
+		case source.ConfigMapRef != nil:
+			for _, msg := range validation.IsDNS1123Subdomain(source.ConfigMapRef.Name) {
+				errs = append(errs, field.Invalid(itemPath.Child("configMapRef", "name"), source.ConfigMapRef.Name, msg))
+			}
+		case source.SecretRef != nil:
+			for _, msg := range validation.IsDNS1123Subdomain(source.SecretRef.Name) {
+				errs = append(errs, field.Invalid(itemPath.Child("secretRef", "name"), source.SecretRef.Name, msg))
+			}
+		default:
+			errs = append(errs,
+				field.Required(itemPath, "One of `configMapRef` and `secretRef` must be set"))
+		}
+	}
+
+	return errs
+}
+
+// validateStorageUpdate rejects the changes a PersistentVolumeClaim can not take
+func (r *SingleDeployment) validateStorageUpdate(old *SingleDeployment, storagePath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	oldStorage := map[string]*Storage{}
+	for i := range old.Spec.Storage {
+		oldStorage[old.Spec.Storage[i].Name] = &old.Spec.Storage[i]
+	}
+	for i, storage := range r.Spec.Storage {
+		itemPath := storagePath.Index(i)
+		o, ok := oldStorage[storage.Name]
+		if !ok {
+			continue
+		}
+		if storage.Size.Cmp(o.Size) < 0 {
+			errs = append(errs,
+				field.Invalid(itemPath.Child("size"), storage.Size.String(), fmt.Sprintf("It can not be less than the previous size %s", o.Size.String())))
+		}
+		if !reflect.DeepEqual(storage.StorageClassName, o.StorageClassName) {
+			errs = append(errs,
+				field.Forbidden(itemPath.Child("storageClassName"), "It can not be changed"))
+		}
+		if !reflect.DeepEqual(storage.AccessModes, o.AccessModes) {
+			errs = append(errs,
+				field.Forbidden(itemPath.Child("accessModes"), "It can not be changed"))
+		}
+	}
+
+	return errs
+}
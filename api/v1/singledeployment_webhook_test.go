@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func Test_validateProbe(t *testing.T) {
+	type args struct {
+		probe      *Probe
+		hasTCPPort bool
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name:    "Test case empty http port with TCP port",
+			args:    args{probe: &Probe{HTTPGet: &HTTPGetProbe{Path: "/healthz"}}, hasTCPPort: true},
+			wantErr: false,
+		},
+		{
+			name:    "Test case empty http port without TCP port",
+			args:    args{probe: &Probe{HTTPGet: &HTTPGetProbe{Path: "/healthz"}}, hasTCPPort: false},
+			wantErr: true,
+		},
+		{
+			name:    "Test case empty tcp port without TCP port",
+			args:    args{probe: &Probe{TCPSocket: &TCPSocketProbe{}}, hasTCPPort: false},
+			wantErr: true,
+		},
+		{
+			name:    "Test case tcp port set without TCP port",
+			args:    args{probe: &Probe{TCPSocket: &TCPSocketProbe{Port: 8080}}, hasTCPPort: false},
+			wantErr: false,
+		},
+		{
+			name:    "Test case exec probe without TCP port",
+			args:    args{probe: &Probe{Exec: &ExecProbe{Command: []string{"true"}}}, hasTCPPort: false},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := validateProbe(tt.args.probe, field.NewPath("spec", "healthCheck", "liveness"), true, tt.args.hasTCPPort)
+			if (len(errs) != 0) != tt.wantErr {
+				t.Errorf("validateProbe() errors = %v, wantErr %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,14 +1,29 @@
 package v1
 
 const (
-	StatusPhaseCreating = "Creating"
-	StatusPhaseRunning  = "Running"
-	StatusPhaseSuccess  = "Success"
-	StatusPhaseFailed   = "Failed"
-	StatusPhaseDeleting = "Deleting"
+	StatusPhaseCreating  = "Creating"
+	StatusPhaseRunning   = "Running"
+	StatusPhaseSuccess   = "Success"
+	StatusPhaseFailed    = "Failed"
+	StatusPhaseDeleting  = "Deleting"
+	StatusPhaseSuspended = "Suspended"
 )
 
 const (
 	StatusReasonDependsUnavailable = "DependsUnavailable"
 	StatusReasonDependsAvailable   = "DependsAvailable"
 )
+
+const (
+	RolloutPhaseProgressing = "Progressing"
+	RolloutPhasePaused      = "Paused"
+	RolloutPhasePromoted    = "Promoted"
+	RolloutPhasePromoting   = "Promoting"
+	RolloutPhaseCompleted   = "Completed"
+	RolloutPhaseAborted     = "Aborted"
+)
+
+const (
+	ColorBlue  = "blue"
+	ColorGreen = "green"
+)
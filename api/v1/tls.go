@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	IssuerKindIssuer        = "Issuer"
+	IssuerKindClusterIssuer = "ClusterIssuer"
+)
+
+func (r *SingleDeployment) defaultTLS() {
+	tls := r.Spec.Expose.TLS
+	if tls == nil || tls.IssuerRef == nil {
+		return
+	}
+	if tls.SecretName == "" {
+		tls.SecretName = r.Name + "-tls"
+	}
+	if tls.IssuerRef.Kind == "" {
+		tls.IssuerRef.Kind = IssuerKindIssuer
+	}
+}
+
+func (r *SingleDeployment) validateTLS(tlsPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	tls := r.Spec.Expose.TLS
+	if tls == nil {
+		return errs
+	}
+	if strings.ToLower(r.Spec.Expose.Mode) != ServiceIngress {
+		errs = append(errs,
+			field.Forbidden(tlsPath, "It can only be set in ingress mode"))
+		return errs
+	}
+
+	if tls.SecretName == "" && tls.IssuerRef == nil {
+		errs = append(errs,
+			field.Required(tlsPath.Child("secretName"), "It must be set when `spec.expose.tls.issuerRef` is empty"))
+	}
+	if tls.SecretName != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(tls.SecretName) {
+			errs = append(errs, field.Invalid(tlsPath.Child("secretName"), tls.SecretName, msg))
+		}
+	}
+	if tls.IssuerRef != nil {
+		issuerRefPath := tlsPath.Child("issuerRef")
+		if tls.IssuerRef.Name == "" {
+			errs = append(errs,
+				field.Required(issuerRefPath.Child("name"), "It must be the name of an Issuer or ClusterIssuer"))
+		}
+		if tls.IssuerRef.Kind != IssuerKindIssuer && tls.IssuerRef.Kind != IssuerKindClusterIssuer {
+			errs = append(errs,
+				field.NotSupported(issuerRefPath.Child("kind"), tls.IssuerRef.Kind, []string{IssuerKindIssuer, IssuerKindClusterIssuer}))
+		}
+	} else if tls.CreateCertificate {
+		errs = append(errs,
+			field.Invalid(tlsPath.Child("createCertificate"), tls.CreateCertificate, "It must be used with `spec.expose.tls.issuerRef`"))
+	}
+
+	return errs
+}
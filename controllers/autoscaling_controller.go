@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+)
+
+// reconcileAutoscaling creates/updates the HorizontalPodAutoscaler of spec.autoscaling, or deletes it
+// when spec.autoscaling is empty or the instance is suspended
+func (r *SingleDeploymentReconciler) reconcileAutoscaling(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) {
+	autoscaling := sd.Spec.Autoscaling != nil && !sd.Spec.Suspend
+	hpa := new(autoscalingv2.HorizontalPodAutoscaler)
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: sd.Name}, hpa); err != nil {
+		if errors.IsNotFound(err) {
+			// Its a "not found error" that is none a hpa, create it if autoscaling is on.
+			if !autoscaling {
+				r.deleteConditions(
+					&sd.Status,
+					deploymentv1.ConditionTypeAutoscaling,
+				)
+			} else if errCreate := r.createHorizontalPodAutoscaler(ctx, logger, sd); errCreate != nil {
+				r.setConditions(
+					&sd.Status,
+					deploymentv1.ConditionTypeAutoscaling,
+					sd.Name,
+					fmt.Sprintf("HorizontalPodAutoscaler \"%s\" create failed: %s", sd.Name, errCreate.Error()),
+					deploymentv1.ConditionStatusFailed,
+					deploymentv1.ConditionReasonAutoscalingUnavailable,
+				)
+			} else {
+				r.setConditions(
+					&sd.Status,
+					deploymentv1.ConditionTypeAutoscaling,
+					sd.Name,
+					fmt.Sprintf("HorizontalPodAutoscaler \"%s\" is created", sd.Name),
+					deploymentv1.ConditionStatusReady,
+					deploymentv1.ConditionReasonAutoscalingAvailable,
+				)
+			}
+		} else {
+			// Its not a "not found err", throw it
+			logger.Error(err, "Get HorizontalPodAutoscaler failed")
+			r.setConditions(
+				&sd.Status,
+				deploymentv1.ConditionTypeAutoscaling,
+				sd.Name,
+				fmt.Sprintf("HorizontalPodAutoscaler \"%s\" get failed: %s", sd.Name, err.Error()),
+				deploymentv1.ConditionStatusFailed,
+				deploymentv1.ConditionReasonAutoscalingUnavailable,
+			)
+		}
+		return
+	}
+
+	if !autoscaling {
+		// The hpa is exist, but autoscaling is off, delete the hpa
+		if err := r.deleteHorizontalPodAutoscaler(ctx, logger, hpa); err != nil {
+			r.setConditions(
+				&sd.Status,
+				deploymentv1.ConditionTypeAutoscaling,
+				sd.Name,
+				fmt.Sprintf("HorizontalPodAutoscaler \"%s\" delete failed: %s", sd.Name, err.Error()),
+				deploymentv1.ConditionStatusFailed,
+				deploymentv1.ConditionReasonAutoscalingUnavailable,
+			)
+		} else {
+			r.deleteConditions(
+				&sd.Status,
+				deploymentv1.ConditionTypeAutoscaling,
+			)
+		}
+		return
+	}
+
+	if err := r.updateHorizontalPodAutoscaler(ctx, logger, sd, hpa); err != nil {
+		logger.Error(err, "Update HorizontalPodAutoscaler failed")
+		r.setConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeAutoscaling,
+			sd.Name,
+			fmt.Sprintf("HorizontalPodAutoscaler \"%s\" update failed: %s", sd.Name, err.Error()),
+			deploymentv1.ConditionStatusFailed,
+			deploymentv1.ConditionReasonAutoscalingUnavailable,
+		)
+		return
+	}
+	r.setConditions(
+		&sd.Status,
+		deploymentv1.ConditionTypeAutoscaling,
+		sd.Name,
+		fmt.Sprintf("HorizontalPodAutoscaler \"%s\" is created", sd.Name),
+		deploymentv1.ConditionStatusReady,
+		deploymentv1.ConditionReasonAutoscalingAvailable,
+	)
+}
+
+// desiredReplicas returns how many replicas the deployment should have available. With autoscaling
+// it is the count the HorizontalPodAutoscaler wants, otherwise it is spec.replicas or the ones of spec.schedule
+func (r *SingleDeploymentReconciler) desiredReplicas(ctx context.Context, sd *deploymentv1.SingleDeployment, deploy *appsv1.Deployment) int32 {
+	if sd.Spec.Suspend {
+		return 0
+	}
+	if sd.Spec.Autoscaling == nil {
+		return instanceReplicas(sd)
+	}
+
+	hpa := new(autoscalingv2.HorizontalPodAutoscaler)
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: sd.Name}, hpa); err == nil &&
+		hpa.Status.DesiredReplicas != 0 {
+		return hpa.Status.DesiredReplicas
+	}
+	// The HorizontalPodAutoscaler has not computed a count yet
+	if deploy.Spec.Replicas != nil {
+		return *deploy.Spec.Replicas
+	}
+	return *autoscalingMinReplicas(sd)
+}
+
+func (r *SingleDeploymentReconciler) generateHorizontalPodAutoscaler(sd *deploymentv1.SingleDeployment) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	hpa := newHorizontalPodAutoscaler(sd)
+	if err := controllerutil.SetControllerReference(sd, hpa, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	return hpa, nil
+}
+
+func (r *SingleDeploymentReconciler) createHorizontalPodAutoscaler(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	hpa, err := r.generateHorizontalPodAutoscaler(sd)
+	if err != nil {
+		return err
+	}
+	if err := r.Client.Create(ctx, hpa); err != nil {
+		logger.Error(err, "Create New HorizontalPodAutoscaler failed")
+		return err
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) updateHorizontalPodAutoscaler(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, h *autoscalingv2.HorizontalPodAutoscaler) error {
+	hpa, err := r.generateHorizontalPodAutoscaler(sd)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Client.Update(ctx, hpa, client.DryRunAll); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(hpa.Spec, h.Spec) {
+		return nil
+	}
+
+	if err := r.Client.Update(ctx, hpa); err != nil {
+		logger.Error(err, "Update New HorizontalPodAutoscaler failed")
+		return err
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) deleteHorizontalPodAutoscaler(ctx context.Context, logger logr.Logger, hpa *autoscalingv2.HorizontalPodAutoscaler) error {
+	if err := r.Client.Delete(ctx, hpa); err != nil {
+		logger.Error(err, "Delete HorizontalPodAutoscaler failed")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+)
+
+// reconcileBuild runs the build job of spec.build and records the built image in status.
+// It returns true when the image used by deployment is ready.
+func (r *SingleDeploymentReconciler) reconcileBuild(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) bool {
+	if sd.Spec.Image != "" || sd.Spec.Build == nil {
+		r.deleteConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeBuild,
+		)
+		return sd.Spec.Image != ""
+	}
+
+	hash := buildHash(sd.Spec.Build)
+	if sd.Status.Build != nil &&
+		sd.Status.Build.Hash == hash &&
+		sd.Status.Build.Image != "" {
+		// Current build spec is built
+		r.setConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeBuild,
+			sd.Name,
+			fmt.Sprintf("Image \"%s\" is built", sd.Status.Build.Image),
+			deploymentv1.ConditionStatusReady,
+			deploymentv1.ConditionReasonBuildAvailable,
+		)
+		return true
+	}
+
+	jobName := buildJobName(sd.Name, hash)
+	job := new(batchv1.Job)
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: jobName}, job); err != nil {
+		if errors.IsNotFound(err) && sd.Status.Build != nil && sd.Status.Build.Hash == hash && sd.Status.Build.Failed {
+			// The failed Job is deleted after its TTL, do not build the same spec again
+			r.setConditions(
+				&sd.Status,
+				deploymentv1.ConditionTypeBuild,
+				sd.Name,
+				fmt.Sprintf("Build job \"%s\" is failed, change spec.build to build again", jobName),
+				deploymentv1.ConditionStatusFailed,
+				deploymentv1.ConditionReasonBuildUnavailable,
+			)
+		} else if errors.IsNotFound(err) {
+			// Its a "not found error" that the build of current spec is not started, create it.
+			if errCreate := r.createBuildJob(ctx, logger, sd); errCreate != nil {
+				r.setConditions(
+					&sd.Status,
+					deploymentv1.ConditionTypeBuild,
+					sd.Name,
+					fmt.Sprintf("Build job \"%s\" create failed: %s", jobName, errCreate.Error()),
+					deploymentv1.ConditionStatusFailed,
+					deploymentv1.ConditionReasonBuildUnavailable,
+				)
+				return false
+			}
+			r.setBuildStatus(&sd.Status, &deploymentv1.BuildStatus{JobName: jobName, Hash: hash})
+			r.setConditions(
+				&sd.Status,
+				deploymentv1.ConditionTypeBuild,
+				sd.Name,
+				fmt.Sprintf("Build job \"%s\" is running", jobName),
+				deploymentv1.ConditionStatusUnKnown,
+				deploymentv1.ConditionReasonBuildUnavailable,
+			)
+		} else {
+			// Its not a "not found err", throw it
+			logger.Error(err, "Get build job failed")
+			r.setConditions(
+				&sd.Status,
+				deploymentv1.ConditionTypeBuild,
+				sd.Name,
+				fmt.Sprintf("Build job \"%s\" get failed: %s", jobName, err.Error()),
+				deploymentv1.ConditionStatusFailed,
+				deploymentv1.ConditionReasonBuildUnavailable,
+			)
+		}
+		return false
+	}
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			digest, err := r.buildDigest(ctx, job)
+			if err != nil {
+				logger.Error(err, "Get digest of built image failed")
+				r.setConditions(
+					&sd.Status,
+					deploymentv1.ConditionTypeBuild,
+					sd.Name,
+					fmt.Sprintf("Build job \"%s\" is completed, but the image digest is unknown: %s", jobName, err.Error()),
+					deploymentv1.ConditionStatusFailed,
+					deploymentv1.ConditionReasonBuildUnavailable,
+				)
+				return false
+			}
+			r.setBuildStatus(&sd.Status, &deploymentv1.BuildStatus{
+				JobName: jobName,
+				Hash:    hash,
+				Digest:  digest,
+				Image:   fmt.Sprintf("%s@%s", sd.Spec.Build.Registry, digest),
+			})
+			r.setConditions(
+				&sd.Status,
+				deploymentv1.ConditionTypeBuild,
+				sd.Name,
+				fmt.Sprintf("Image \"%s\" is built", sd.Status.Build.Image),
+				deploymentv1.ConditionStatusReady,
+				deploymentv1.ConditionReasonBuildAvailable,
+			)
+			return true
+		case batchv1.JobFailed:
+			r.setBuildStatus(&sd.Status, &deploymentv1.BuildStatus{JobName: jobName, Hash: hash, Failed: true})
+			r.setConditions(
+				&sd.Status,
+				deploymentv1.ConditionTypeBuild,
+				sd.Name,
+				fmt.Sprintf("Build job \"%s\" is failed: %s", jobName, cond.Message),
+				deploymentv1.ConditionStatusFailed,
+				deploymentv1.ConditionReasonBuildUnavailable,
+			)
+			return false
+		}
+	}
+
+	r.setBuildStatus(&sd.Status, &deploymentv1.BuildStatus{JobName: jobName, Hash: hash})
+	r.setConditions(
+		&sd.Status,
+		deploymentv1.ConditionTypeBuild,
+		sd.Name,
+		fmt.Sprintf("Build job \"%s\" is running", jobName),
+		deploymentv1.ConditionStatusUnKnown,
+		deploymentv1.ConditionReasonBuildUnavailable,
+	)
+	return false
+}
+
+func (r *SingleDeploymentReconciler) generateBuildJob(sd *deploymentv1.SingleDeployment) (*batchv1.Job, error) {
+	job, err := newBuildJob(sd)
+	if err != nil {
+		return nil, err
+	}
+	err = controllerutil.SetControllerReference(sd, job, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (r *SingleDeploymentReconciler) createBuildJob(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	job, err := r.generateBuildJob(sd)
+	if err != nil {
+		return err
+	}
+	if err := r.Client.Create(ctx, job); err != nil {
+		logger.Error(err, "Create New build job failed")
+		return err
+	}
+
+	return nil
+}
+
+// buildDigest reads the digest the builder wrote to the termination message of its container
+func (r *SingleDeploymentReconciler) buildDigest(ctx context.Context, job *batchv1.Job) (string, error) {
+	pods := new(corev1.PodList)
+	if err := r.Client.List(ctx, pods,
+		client.InNamespace(job.Namespace),
+		client.MatchingLabels{"job-name": job.Name},
+	); err != nil {
+		return "", err
+	}
+
+	for i := range pods.Items {
+		for _, status := range pods.Items[i].Status.ContainerStatuses {
+			if status.Name != BuildContainerName ||
+				status.State.Terminated == nil ||
+				status.State.Terminated.ExitCode != 0 {
+				continue
+			}
+			return parseBuildDigest(status.State.Terminated.Message)
+		}
+	}
+
+	return "", fmt.Errorf("no succeeded pod found for job \"%s\"", job.Name)
+}
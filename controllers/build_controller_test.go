@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func Test_reconcileBuild_failed(t *testing.T) {
+	sd := makeSingleDeployment("deployment_v1_singledeployment_rc_build_git.yaml")
+	sd.UID = types.UID("singledeployment-sample-build")
+	hash := buildHash(sd.Spec.Build)
+	changed := sd.DeepCopy()
+	changed.Spec.Build.Registry += "-changed"
+
+	tests := []struct {
+		name    string
+		sd      *deploymentv1.SingleDeployment
+		wantJob bool
+	}{
+		{
+			name:    "Test case failed build job is not created again",
+			sd:      sd,
+			wantJob: false,
+		},
+		{
+			name:    "Test case build job is created after spec.build changes",
+			sd:      changed,
+			wantJob: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd := tt.sd.DeepCopy()
+			// The failed Job is deleted after its TTL
+			sd.Status.Build = &deploymentv1.BuildStatus{JobName: buildJobName(sd.Name, hash), Hash: hash, Failed: true}
+			r := newTestReconciler()
+			if r.reconcileBuild(context.Background(), log.Log, sd) {
+				t.Errorf("reconcileBuild() = true, want false")
+			}
+
+			jobName := buildJobName(sd.Name, buildHash(sd.Spec.Build))
+			err := r.Client.Get(context.Background(), types.NamespacedName{Namespace: sd.Namespace, Name: jobName}, new(batchv1.Job))
+			if gotJob := err == nil; gotJob != tt.wantJob {
+				t.Errorf("reconcileBuild() job created = %v, want %v, get error = %v", gotJob, tt.wantJob, err)
+			}
+			if err != nil && !errors.IsNotFound(err) {
+				t.Fatal(err)
+			}
+			if cond := sd.Status.Conditions; !tt.wantJob && (len(cond) == 0 || cond[0].Status != deploymentv1.ConditionStatusFailed) {
+				t.Errorf("reconcileBuild() conditions = %v, want build failed", cond)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+)
+
+//+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
+
+// certManagerInstalled returns true when the Certificate of cert-manager is served by the cluster
+func certManagerInstalled(mapper meta.RESTMapper) bool {
+	_, err := mapper.RESTMapping(CertificateGroupVersionKind.GroupKind(), CertificateGroupVersionKind.Version)
+	return err == nil
+}
+
+// reconcileCertificate creates the Certificate of spec.expose.tls.createCertificate, and reports the expiry of
+// the certificate in the Secret of the Ingress. It returns the duration after which the certificate expires.
+func (r *SingleDeploymentReconciler) reconcileCertificate(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) time.Duration {
+	tls := sd.Spec.Expose.TLS
+	if strings.ToLower(sd.Spec.Expose.Mode) != ServiceIngress {
+		tls = nil
+	}
+	createCertificate := tls != nil && tls.IssuerRef != nil && tls.CreateCertificate
+
+	if r.certManagerInstalled {
+		if !r.reconcileCertificateResource(ctx, logger, sd, createCertificate) {
+			return 0
+		}
+	} else if createCertificate {
+		r.setCertificateCondition(sd, "Certificate is not supported, cert-manager is not installed in the cluster", deploymentv1.ConditionStatusFailed)
+		return 0
+	}
+
+	if tls == nil {
+		r.deleteConditions(&sd.Status, deploymentv1.ConditionTypeCertificate)
+		return 0
+	}
+
+	secret := new(corev1.Secret)
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: tls.SecretName}, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			logger.Error(err, "Get certificate Secret failed")
+			r.setCertificateCondition(sd, fmt.Sprintf("Secret \"%s\" is get failed: %s", tls.SecretName, err.Error()), deploymentv1.ConditionStatusFailed)
+		} else if tls.IssuerRef != nil {
+			r.setCertificateCondition(sd, fmt.Sprintf("Secret \"%s\" is waiting for the certificate to be issued", tls.SecretName), deploymentv1.ConditionStatusUnKnown)
+		} else {
+			r.setCertificateCondition(sd, fmt.Sprintf("Secret \"%s\" is not found", tls.SecretName), deploymentv1.ConditionStatusFailed)
+		}
+		return 0
+	}
+
+	notAfter, err := certificateNotAfter(secret)
+	if err != nil {
+		r.setCertificateCondition(sd, fmt.Sprintf("Secret \"%s\" has no valid certificate: %s", tls.SecretName, err.Error()), deploymentv1.ConditionStatusFailed)
+		return 0
+	}
+	expiry := notAfter.UTC().Format(time.RFC3339)
+	if !time.Now().Before(notAfter) {
+		r.setCertificateCondition(sd, fmt.Sprintf("Certificate in Secret \"%s\" expired at %s", tls.SecretName, expiry), deploymentv1.ConditionStatusFailed)
+		return 0
+	}
+	r.setCertificateCondition(sd, fmt.Sprintf("Certificate in Secret \"%s\" expires at %s", tls.SecretName, expiry), deploymentv1.ConditionStatusReady)
+
+	return time.Until(notAfter)
+}
+
+// reconcileCertificateResource creates or updates the Certificate owned by instance, and deletes it when it is
+// not needed. It returns false when the certificate condition is set and the Secret should not be checked.
+func (r *SingleDeploymentReconciler) reconcileCertificateResource(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, createCertificate bool) bool {
+	certificate := newBaseCertificate(sd.Name, sd.Namespace)
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(certificate), certificate); err != nil {
+		if !errors.IsNotFound(err) {
+			logger.Error(err, "Get Certificate failed")
+			r.setCertificateCondition(sd, fmt.Sprintf("Certificate \"%s\" is get failed: %s", sd.Name, err.Error()), deploymentv1.ConditionStatusFailed)
+			return false
+		}
+		if !createCertificate {
+			return true
+		}
+		if err := r.createCertificate(ctx, logger, sd); err != nil {
+			r.setCertificateCondition(sd, fmt.Sprintf("Certificate \"%s\" is create failed: %s", sd.Name, err.Error()), deploymentv1.ConditionStatusFailed)
+			return false
+		}
+		r.setCertificateCondition(sd, fmt.Sprintf("Certificate \"%s\" is created, waiting for it to be issued", sd.Name), deploymentv1.ConditionStatusUnKnown)
+		return false
+	}
+
+	if !createCertificate {
+		if metav1.IsControlledBy(certificate, sd) {
+			if err := r.Client.Delete(ctx, certificate); client.IgnoreNotFound(err) != nil {
+				logger.Error(err, "Delete Certificate failed")
+				r.setCertificateCondition(sd, fmt.Sprintf("Certificate \"%s\" is delete failed: %s", sd.Name, err.Error()), deploymentv1.ConditionStatusFailed)
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := r.updateCertificate(ctx, logger, sd, certificate); err != nil {
+		r.setCertificateCondition(sd, fmt.Sprintf("Certificate \"%s\" is update failed: %s", sd.Name, err.Error()), deploymentv1.ConditionStatusFailed)
+		return false
+	}
+	if ready, message := certificateReady(certificate); !ready {
+		r.setCertificateCondition(sd, fmt.Sprintf("Certificate \"%s\" is not ready: %s", sd.Name, message), deploymentv1.ConditionStatusUnKnown)
+		return false
+	}
+
+	return true
+}
+
+func (r *SingleDeploymentReconciler) createCertificate(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	certificate := newCertificate(sd)
+	if err := controllerutil.SetControllerReference(sd, certificate, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Client.Create(ctx, certificate); err != nil {
+		logger.Error(err, "Create Certificate failed")
+		return err
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) updateCertificate(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, cert *unstructured.Unstructured) error {
+	certificate := newCertificate(sd)
+	if err := controllerutil.SetControllerReference(sd, certificate, r.Scheme); err != nil {
+		return err
+	}
+	certificate.SetResourceVersion(cert.GetResourceVersion())
+
+	if err := r.Client.Update(ctx, certificate, client.DryRunAll); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(certificate.Object["spec"], cert.Object["spec"]) {
+		return nil
+	}
+
+	if err := r.Client.Update(ctx, certificate); err != nil {
+		logger.Error(err, "Update Certificate failed")
+		return err
+	}
+
+	return nil
+}
+
+// certificateReady returns the Ready condition cert-manager sets on the Certificate, with its message
+func certificateReady(certificate *unstructured.Unstructured) (bool, string) {
+	conditions, _, _ := unstructured.NestedSlice(certificate.Object, "status", "conditions")
+	for _, condition := range conditions {
+		conditionMap, ok := condition.(map[string]interface{})
+		if !ok || conditionMap["type"] != "Ready" {
+			continue
+		}
+		message, _ := conditionMap["message"].(string)
+		return conditionMap["status"] == string(metav1.ConditionTrue), message
+	}
+
+	return false, "waiting for it to be issued"
+}
+
+// certificateNotAfter returns the expiry of the first certificate in the tls.crt of Secret
+func certificateNotAfter(secret *corev1.Secret) (time.Time, error) {
+	block, _ := pem.Decode(secret.Data[corev1.TLSCertKey])
+	if block == nil {
+		return time.Time{}, fmt.Errorf("%s is not a PEM certificate", corev1.TLSCertKey)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return cert.NotAfter, nil
+}
+
+// tlsSecretIndexer indexes SingleDeployments by the name of Secret in spec.expose.tls
+func tlsSecretIndexer(obj client.Object) []string {
+	sd, ok := obj.(*deploymentv1.SingleDeployment)
+	if !ok || sd.Spec.Expose.TLS == nil || sd.Spec.Expose.TLS.SecretName == "" {
+		return nil
+	}
+	return []string{sd.Spec.Expose.TLS.SecretName}
+}
+
+func (r *SingleDeploymentReconciler) setCertificateCondition(sd *deploymentv1.SingleDeployment, message, status string) {
+	reason := deploymentv1.ConditionReasonCertificateUnavailable
+	if status == deploymentv1.ConditionStatusReady {
+		reason = deploymentv1.ConditionReasonCertificateAvailable
+	}
+	r.setConditions(
+		&sd.Status,
+		deploymentv1.ConditionTypeCertificate,
+		sd.Name,
+		message,
+		status,
+		reason,
+	)
+}
@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+)
+
+// reconcileConfig creates/updates the ConfigMap of spec.configFiles, and checks the Secrets and ConfigMaps
+// referenced by spec.envFrom. It returns false when some reference is missing, and the deployment
+// should not be created or updated.
+func (r *SingleDeploymentReconciler) reconcileConfig(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) bool {
+	hash, err := r.envFromHash(ctx, sd)
+	if err != nil {
+		logger.Error(err, "Get envFrom references failed")
+		r.setConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeConfig,
+			sd.Name,
+			err.Error(),
+			deploymentv1.ConditionStatusFailed,
+			deploymentv1.ConditionReasonConfigUnavailable,
+		)
+		return false
+	}
+	r.setEnvFromHash(&sd.Status, hash)
+
+	if err := r.reconcileConfigMap(ctx, logger, sd); err != nil {
+		r.setConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeConfig,
+			sd.Name,
+			err.Error(),
+			deploymentv1.ConditionStatusFailed,
+			deploymentv1.ConditionReasonConfigUnavailable,
+		)
+		return true
+	}
+
+	if len(sd.Spec.ConfigFiles) == 0 && len(sd.Spec.EnvFrom) == 0 {
+		r.deleteConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeConfig,
+		)
+		return true
+	}
+	r.setConditions(
+		&sd.Status,
+		deploymentv1.ConditionTypeConfig,
+		sd.Name,
+		fmt.Sprintf("%d config files and %d envFrom references are ready", len(sd.Spec.ConfigFiles), len(sd.Spec.EnvFrom)),
+		deploymentv1.ConditionStatusReady,
+		deploymentv1.ConditionReasonConfigAvailable,
+	)
+	return true
+}
+
+// reconcileConfigMap creates/updates the ConfigMap of spec.configFiles, or deletes it when spec.configFiles is empty
+func (r *SingleDeploymentReconciler) reconcileConfigMap(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	name := configMapName(sd.Name)
+	configMap := new(corev1.ConfigMap)
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: name}, configMap); err != nil {
+		if !errors.IsNotFound(err) {
+			// Its not a "not found err", throw it
+			logger.Error(err, "Get configmap failed")
+			return fmt.Errorf("ConfigMap \"%s\" get failed: %s", name, err.Error())
+		}
+		// Its a "not found error" that is none a configmap, create it if there are config files.
+		if len(sd.Spec.ConfigFiles) == 0 {
+			return nil
+		}
+		if err := r.createConfigMap(ctx, logger, sd); err != nil {
+			return fmt.Errorf("ConfigMap \"%s\" create failed: %s", name, err.Error())
+		}
+		return nil
+	}
+
+	if !metav1.IsControlledBy(configMap, sd) {
+		// The configmap is created by others, e.g. referenced by spec.envFrom, keep it
+		if len(sd.Spec.ConfigFiles) == 0 {
+			return nil
+		}
+		return fmt.Errorf("ConfigMap \"%s\" exists and is not controlled by SingleDeployment \"%s\"", name, sd.Name)
+	}
+
+	if len(sd.Spec.ConfigFiles) == 0 {
+		// The configmap is exist, but there is no config file, delete the configmap
+		if err := r.deleteConfigMap(ctx, logger, configMap); err != nil {
+			return fmt.Errorf("ConfigMap \"%s\" delete failed: %s", name, err.Error())
+		}
+		return nil
+	}
+
+	if err := r.updateConfigMap(ctx, logger, sd, configMap); err != nil {
+		return fmt.Errorf("ConfigMap \"%s\" update failed: %s", name, err.Error())
+	}
+	return nil
+}
+
+// envFromHash hashes the data of the Secrets and ConfigMaps referenced by spec.envFrom.
+// It returns an error when a reference which is not optional is missing.
+func (r *SingleDeploymentReconciler) envFromHash(ctx context.Context, sd *deploymentv1.SingleDeployment) (string, error) {
+	if len(sd.Spec.EnvFrom) == 0 {
+		return "", nil
+	}
+
+	type envFromData struct {
+		Kind string            `json:"kind"`
+		Name string            `json:"name"`
+		Data map[string][]byte `json:"data,omitempty"`
+	}
+	sources := make([]envFromData, 0, len(sd.Spec.EnvFrom))
+	for _, source := range sd.Spec.EnvFrom {
+		switch {
+		case source.ConfigMapRef != nil:
+			configMap := new(corev1.ConfigMap)
+			err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: source.ConfigMapRef.Name}, configMap)
+			if err != nil && !(errors.IsNotFound(err) && isOptional(source.ConfigMapRef.Optional)) {
+				return "", fmt.Errorf("ConfigMap \"%s\" of envFrom get failed: %s", source.ConfigMapRef.Name, err.Error())
+			}
+			data := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+			for k, v := range configMap.Data {
+				data[k] = []byte(v)
+			}
+			for k, v := range configMap.BinaryData {
+				data[k] = v
+			}
+			sources = append(sources, envFromData{Kind: "ConfigMap", Name: source.ConfigMapRef.Name, Data: data})
+		case source.SecretRef != nil:
+			secret := new(corev1.Secret)
+			err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: source.SecretRef.Name}, secret)
+			if err != nil && !(errors.IsNotFound(err) && isOptional(source.SecretRef.Optional)) {
+				return "", fmt.Errorf("Secret \"%s\" of envFrom get failed: %s", source.SecretRef.Name, err.Error())
+			}
+			sources = append(sources, envFromData{Kind: "Secret", Name: source.SecretRef.Name, Data: secret.Data})
+		}
+	}
+
+	data, _ := json.Marshal(sources)
+	hasher := fnv.New32a()
+	hasher.Write(data)
+	return fmt.Sprintf("%08x", hasher.Sum32()), nil
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
+
+// findReferencing maps a Secret or ConfigMap to the SingleDeployments referencing it by the index fields
+func (r *SingleDeploymentReconciler) findReferencing(indexFields ...string) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		found := map[types.NamespacedName]bool{}
+		var requests []reconcile.Request
+		for _, indexField := range indexFields {
+			list := new(deploymentv1.SingleDeploymentList)
+			if err := r.Client.List(context.Background(), list,
+				client.InNamespace(obj.GetNamespace()),
+				client.MatchingFields{indexField: obj.GetName()},
+			); err != nil {
+				continue
+			}
+
+			for i := range list.Items {
+				name := types.NamespacedName{Namespace: list.Items[i].Namespace, Name: list.Items[i].Name}
+				if !found[name] {
+					found[name] = true
+					requests = append(requests, reconcile.Request{NamespacedName: name})
+				}
+			}
+		}
+		return requests
+	}
+}
+
+// envFromIndexer indexes SingleDeployments by the names of ConfigMaps or Secrets in spec.envFrom
+func envFromIndexer(secret bool) client.IndexerFunc {
+	return func(obj client.Object) []string {
+		sd, ok := obj.(*deploymentv1.SingleDeployment)
+		if !ok {
+			return nil
+		}
+
+		var names []string
+		for _, source := range sd.Spec.EnvFrom {
+			if secret && source.SecretRef != nil {
+				names = append(names, source.SecretRef.Name)
+			}
+			if !secret && source.ConfigMapRef != nil {
+				names = append(names, source.ConfigMapRef.Name)
+			}
+		}
+		return names
+	}
+}
+
+// registryAuthIndexer indexes SingleDeployments by the name of Secret in spec.registryAuth
+func registryAuthIndexer(obj client.Object) []string {
+	sd, ok := obj.(*deploymentv1.SingleDeployment)
+	if !ok || sd.Spec.RegistryAuth == nil {
+		return nil
+	}
+	return []string{sd.Spec.RegistryAuth.SecretName}
+}
+
+func (r *SingleDeploymentReconciler) generateConfigMap(sd *deploymentv1.SingleDeployment) (*corev1.ConfigMap, error) {
+	configMap := newConfigMap(sd)
+	if err := controllerutil.SetControllerReference(sd, configMap, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	return configMap, nil
+}
+
+func (r *SingleDeploymentReconciler) createConfigMap(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	configMap, err := r.generateConfigMap(sd)
+	if err != nil {
+		return err
+	}
+	if err := r.Client.Create(ctx, configMap); err != nil {
+		logger.Error(err, "Create New configmap failed")
+		return err
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) updateConfigMap(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, cm *corev1.ConfigMap) error {
+	configMap, err := r.generateConfigMap(sd)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Client.Update(ctx, configMap, client.DryRunAll); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(configMap.Data, cm.Data) {
+		return nil
+	}
+
+	if err := r.Client.Update(ctx, configMap); err != nil {
+		logger.Error(err, "Update New configmap failed")
+		return err
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) deleteConfigMap(ctx context.Context, logger logr.Logger, configMap *corev1.ConfigMap) error {
+	if err := r.Client.Delete(ctx, configMap); err != nil {
+		logger.Error(err, "Delete configmap failed")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func newTestReconciler(objs ...client.Object) *SingleDeploymentReconciler {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{corev1.AddToScheme, batchv1.AddToScheme, netv1.AddToScheme, deploymentv1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			panic(err)
+		}
+	}
+
+	return &SingleDeploymentReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		Scheme: scheme,
+	}
+}
+
+func Test_reconcileConfigMap(t *testing.T) {
+	withConfigFiles := makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_config.yaml")
+	withConfigFiles.UID = types.UID("singledeployment-sample-ingress")
+	withoutConfigFiles := withConfigFiles.DeepCopy()
+	withoutConfigFiles.Spec.ConfigFiles = nil
+
+	foreign := &corev1.ConfigMap{}
+	foreign.Name = configMapName(withConfigFiles.Name)
+	foreign.Namespace = withConfigFiles.Namespace
+	foreign.Data = map[string]string{"key": "value"}
+
+	owned := foreign.DeepCopy()
+	if err := controllerutil.SetControllerReference(withConfigFiles, owned, newTestReconciler().Scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		sd       *deploymentv1.SingleDeployment
+		existing *corev1.ConfigMap
+		// want is the data of ConfigMap after reconcile, nil means it is deleted
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:     "Test case not controlled configmap is not overwritten",
+			sd:       withConfigFiles,
+			existing: foreign,
+			want:     foreign.Data,
+			wantErr:  true,
+		},
+		{
+			name:     "Test case not controlled configmap is not deleted",
+			sd:       withoutConfigFiles,
+			existing: foreign,
+			want:     foreign.Data,
+			wantErr:  false,
+		},
+		{
+			name:     "Test case controlled configmap is deleted",
+			sd:       withoutConfigFiles,
+			existing: owned,
+			want:     nil,
+			wantErr:  false,
+		},
+		{
+			name:     "Test case controlled configmap is updated",
+			sd:       withConfigFiles,
+			existing: owned,
+			want:     configFilesData(withConfigFiles.Spec.ConfigFiles),
+			wantErr:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReconciler(tt.existing.DeepCopy())
+			err := r.reconcileConfigMap(context.Background(), log.Log, tt.sd)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("reconcileConfigMap() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			got := new(corev1.ConfigMap)
+			err = r.Client.Get(context.Background(), client.ObjectKeyFromObject(tt.existing), got)
+			if tt.want == nil {
+				if !errors.IsNotFound(err) {
+					t.Errorf("reconcileConfigMap() ConfigMap is not deleted, get error = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Data, tt.want) {
+				t.Errorf("reconcileConfigMap() data = %v, want %v", got.Data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+)
+
+// ManagedAnnotationsAnnotation lists the annotation keys the controller set on an object, only they are
+// removed when they are not desired any more
+const ManagedAnnotationsAnnotation = "deployment.github.com/managed-annotations"
+
+// mergeAnnotations returns the current annotations with the desired ones set, the ones set by the controller
+// before and not desired any more are removed, and the others are kept
+func mergeAnnotations(current, desired map[string]string) map[string]string {
+	merged := make(map[string]string, len(current)+len(desired)+1)
+	for key, value := range current {
+		merged[key] = value
+	}
+	for _, key := range strings.Split(current[ManagedAnnotationsAnnotation], ",") {
+		delete(merged, key)
+	}
+	delete(merged, ManagedAnnotationsAnnotation)
+
+	keys := make([]string, 0, len(desired))
+	for key, value := range desired {
+		merged[key] = value
+		keys = append(keys, key)
+	}
+	if len(keys) != 0 {
+		sort.Strings(keys)
+		merged[ManagedAnnotationsAnnotation] = strings.Join(keys, ",")
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+
+	return merged
+}
+
+// reconcileRouteGroupIngresses creates or updates the desired Ingresses of the route group, and deletes the ones of
+// the group which are not desired any more
+func (r *SingleDeploymentReconciler) reconcileRouteGroupIngresses(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, group string, desired []*netv1.Ingress) error {
+	names := map[string]bool{}
+	for _, ingress := range desired {
+		names[ingress.Name] = true
+		if err := r.applyIngress(ctx, logger, sd, ingress); err != nil {
+			return fmt.Errorf("Ingress \"%s\" apply failed: %s", ingress.Name, err.Error())
+		}
+	}
+
+	list := new(netv1.IngressList)
+	if err := r.Client.List(ctx, list,
+		client.InNamespace(sd.Namespace),
+		client.MatchingLabels{RouteGroupLabel: group},
+	); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		if names[list.Items[i].Name] || !metav1.IsControlledBy(&list.Items[i], sd) {
+			continue
+		}
+		if err := r.Client.Delete(ctx, &list.Items[i]); err != nil && !errors.IsNotFound(err) {
+			logger.Error(err, "Delete route group Ingress failed", "name", list.Items[i].Name)
+			return fmt.Errorf("Ingress \"%s\" delete failed: %s", list.Items[i].Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// applyIngress creates the Ingress owned by instance, or updates it like updateIngress
+func (r *SingleDeploymentReconciler) applyIngress(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, ingress *netv1.Ingress) error {
+	if err := controllerutil.SetControllerReference(sd, ingress, r.Scheme); err != nil {
+		return err
+	}
+
+	current := new(netv1.Ingress)
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(ingress), current); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		ingress.Annotations = mergeAnnotations(nil, ingress.Annotations)
+		if err := r.Client.Create(ctx, ingress); err != nil {
+			logger.Error(err, "Create New Ingress failed", "name", ingress.Name)
+			return err
+		}
+		return nil
+	}
+	if !metav1.IsControlledBy(current, sd) {
+		return fmt.Errorf("it exists and is not controlled by SingleDeployment \"%s\"", sd.Name)
+	}
+
+	ingress.Annotations = mergeAnnotations(current.Annotations, ingress.Annotations)
+	ingress.SetResourceVersion(current.GetResourceVersion())
+	if err := r.Client.Update(ctx, ingress, client.DryRunAll); err != nil {
+		return err
+	}
+	if reflect.DeepEqual(ingress.Spec, current.Spec) &&
+		reflect.DeepEqual(ingress.Annotations, current.Annotations) &&
+		reflect.DeepEqual(ingress.Labels, current.Labels) {
+		return nil
+	}
+	if err := r.Client.Update(ctx, ingress); err != nil {
+		logger.Error(err, "Update Ingress failed", "name", ingress.Name)
+		return err
+	}
+
+	return nil
+}
+
+// reconcileServiceAddress records the external addresses of the Service in loadbalancer mode. It returns false
+// when the load balancer has no address assigned yet
+func (r *SingleDeploymentReconciler) reconcileServiceAddress(sd *deploymentv1.SingleDeployment, service *corev1.Service) bool {
+	if strings.ToLower(sd.Spec.Expose.Mode) != ServiceLoadBalancer {
+		r.setExternalAddresses(&sd.Status, nil)
+		return true
+	}
+
+	var addresses []string
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			addresses = append(addresses, ingress.IP)
+		}
+		if ingress.Hostname != "" {
+			addresses = append(addresses, ingress.Hostname)
+		}
+	}
+	r.setExternalAddresses(&sd.Status, addresses)
+
+	return len(addresses) != 0
+}
+
+func (r *SingleDeploymentReconciler) setServiceAddressCondition(sd *deploymentv1.SingleDeployment) {
+	r.setConditions(
+		&sd.Status,
+		deploymentv1.ConditionTypeService,
+		sd.Name,
+		fmt.Sprintf("Service \"%s\" is waiting for the load balancer address", sd.Name),
+		deploymentv1.ConditionStatusUnKnown,
+		deploymentv1.ConditionReasonServiceUnavailable,
+	)
+}
+
+func (r *SingleDeploymentReconciler) setExternalAddresses(
+	sdStatus *deploymentv1.SingleDeploymentStatus,
+	addresses []string,
+) {
+	if reflect.DeepEqual(sdStatus.ExternalAddresses, addresses) {
+		return
+	}
+	sdStatus.ExternalAddresses = addresses
+	sdStatus.ObservedGeneration++
+}
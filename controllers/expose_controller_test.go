@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func Test_mergeAnnotations(t *testing.T) {
+	type args struct {
+		current map[string]string
+		desired map[string]string
+	}
+	tests := []struct {
+		name string
+		args args
+		want map[string]string
+	}{
+		{
+			name: "Test case no annotations",
+			args: args{},
+			want: nil,
+		},
+		{
+			name: "Test case desired annotations are recorded as managed",
+			args: args{
+				desired: map[string]string{"b": "2", "a": "1"},
+			},
+			want: map[string]string{"a": "1", "b": "2", ManagedAnnotationsAnnotation: "a,b"},
+		},
+		{
+			name: "Test case annotations of others are kept",
+			args: args{
+				current: map[string]string{"metallb.universe.tf/ip-allocated-from-pool": "default", "a": "1", ManagedAnnotationsAnnotation: "a"},
+				desired: map[string]string{"a": "2"},
+			},
+			want: map[string]string{"metallb.universe.tf/ip-allocated-from-pool": "default", "a": "2", ManagedAnnotationsAnnotation: "a"},
+		},
+		{
+			name: "Test case managed annotations not desired are removed",
+			args: args{
+				current: map[string]string{"other": "kept", "a": "1", "b": "2", ManagedAnnotationsAnnotation: "a,b"},
+				desired: map[string]string{"b": "2"},
+			},
+			want: map[string]string{"other": "kept", "b": "2", ManagedAnnotationsAnnotation: "b"},
+		},
+		{
+			name: "Test case all managed annotations are removed",
+			args: args{
+				current: map[string]string{"a": "1", ManagedAnnotationsAnnotation: "a"},
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeAnnotations(tt.args.current, tt.args.desired); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeAnnotations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_reconcileRouteGroupIngresses(t *testing.T) {
+	sd := makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_rewrite.yaml")
+	sd.UID = types.UID("singledeployment-sample-ingress")
+	scheme := newTestReconciler().Scheme
+
+	// stale is the Ingress of a rewrite target removed from spec.expose.routes
+	stale := &netv1.Ingress{}
+	stale.Name = routeGroupName(sd.Name, "/$1")
+	stale.Namespace = sd.Namespace
+	stale.Labels = map[string]string{RouteGroupLabel: sd.Name}
+	if err := controllerutil.SetControllerReference(sd, stale, scheme); err != nil {
+		t.Fatal(err)
+	}
+	foreign := stale.DeepCopy()
+	foreign.Name = "foreign"
+	foreign.OwnerReferences = nil
+
+	tests := []struct {
+		name    string
+		desired []*netv1.Ingress
+		// want is the names of Ingresses of the group after reconcile
+		want []string
+	}{
+		{
+			name:    "Test case route group ingresses are created and stale ones are deleted",
+			desired: newRouteGroupIngresses(sd),
+			want:    []string{"foreign", routeGroupName(sd.Name, "")},
+		},
+		{
+			name:    "Test case route group ingresses are deleted",
+			desired: nil,
+			want:    []string{"foreign"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReconciler(stale.DeepCopy(), foreign.DeepCopy())
+			if err := r.reconcileRouteGroupIngresses(context.Background(), log.Log, sd, sd.Name, tt.desired); err != nil {
+				t.Fatal(err)
+			}
+
+			list := new(netv1.IngressList)
+			if err := r.Client.List(context.Background(), list, client.MatchingLabels{RouteGroupLabel: sd.Name}); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for i := range list.Items {
+				got = append(got, list.Items[i].Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reconcileRouteGroupIngresses() ingresses = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
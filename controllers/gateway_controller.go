@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+)
+
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
+
+// gatewayInstalled returns true when the HTTPRoute of Gateway API is served by the cluster
+func gatewayInstalled(mapper meta.RESTMapper) bool {
+	_, err := mapper.RESTMapping(HTTPRouteGroupVersionKind.GroupKind(), HTTPRouteGroupVersionKind.Version)
+	return err == nil
+}
+
+// reconcileGateway creates the HTTPRoute of instance in gateway mode and deletes it in the other modes. The
+// route condition follows the Accepted condition the Gateways report on the HTTPRoute.
+func (r *SingleDeploymentReconciler) reconcileGateway(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) {
+	gatewayMode := strings.ToLower(sd.Spec.Expose.Mode) == ServiceGateway
+	if !r.gatewayInstalled {
+		if gatewayMode {
+			r.setRouteCondition(sd, "HTTPRoute is not supported, Gateway API is not installed in the cluster", deploymentv1.ConditionStatusFailed)
+		} else {
+			r.deleteConditions(&sd.Status, deploymentv1.ConditionTypeRoute)
+		}
+		return
+	}
+
+	route := newBaseHTTPRoute(sd.Name, sd.Namespace)
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(route), route); err != nil {
+		if !errors.IsNotFound(err) {
+			logger.Error(err, "Get HTTPRoute failed")
+			r.setRouteCondition(sd, fmt.Sprintf("HTTPRoute \"%s\" is get failed: %s", sd.Name, err.Error()), deploymentv1.ConditionStatusFailed)
+			return
+		}
+		if !gatewayMode {
+			r.deleteConditions(&sd.Status, deploymentv1.ConditionTypeRoute)
+			return
+		}
+		if err := r.createHTTPRoute(ctx, logger, sd); err != nil {
+			r.setRouteCondition(sd, fmt.Sprintf("HTTPRoute \"%s\" is create failed: %s", sd.Name, err.Error()), deploymentv1.ConditionStatusFailed)
+			return
+		}
+		r.setRouteCondition(sd, fmt.Sprintf("HTTPRoute \"%s\" is created, waiting for the Gateway to accept it", sd.Name), deploymentv1.ConditionStatusUnKnown)
+		return
+	}
+
+	if !gatewayMode {
+		if metav1.IsControlledBy(route, sd) {
+			if err := r.Client.Delete(ctx, route); client.IgnoreNotFound(err) != nil {
+				logger.Error(err, "Delete HTTPRoute failed")
+				r.setRouteCondition(sd, fmt.Sprintf("HTTPRoute \"%s\" is delete failed: %s", sd.Name, err.Error()), deploymentv1.ConditionStatusFailed)
+				return
+			}
+		}
+		r.deleteConditions(&sd.Status, deploymentv1.ConditionTypeRoute)
+		return
+	}
+
+	if err := r.updateHTTPRoute(ctx, logger, sd, route); err != nil {
+		r.setRouteCondition(sd, fmt.Sprintf("HTTPRoute \"%s\" is update failed: %s", sd.Name, err.Error()), deploymentv1.ConditionStatusFailed)
+		return
+	}
+	message, status := httpRouteAccepted(sd.Name, route)
+	r.setRouteCondition(sd, message, status)
+}
+
+func (r *SingleDeploymentReconciler) createHTTPRoute(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	route := newHTTPRoute(sd)
+	if err := controllerutil.SetControllerReference(sd, route, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Client.Create(ctx, route); err != nil {
+		logger.Error(err, "Create HTTPRoute failed")
+		return err
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) updateHTTPRoute(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, rt *unstructured.Unstructured) error {
+	route := newHTTPRoute(sd)
+	if err := controllerutil.SetControllerReference(sd, route, r.Scheme); err != nil {
+		return err
+	}
+	route.SetResourceVersion(rt.GetResourceVersion())
+
+	if err := r.Client.Update(ctx, route, client.DryRunAll); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(route.Object["spec"], rt.Object["spec"]) {
+		return nil
+	}
+
+	if err := r.Client.Update(ctx, route); err != nil {
+		logger.Error(err, "Update HTTPRoute failed")
+		return err
+	}
+
+	return nil
+}
+
+// httpRouteAccepted returns the message and status of the route condition from the conditions the parent
+// Gateways set in status.parents of the HTTPRoute
+func httpRouteAccepted(name string, route *unstructured.Unstructured) (string, string) {
+	parents, _, _ := unstructured.NestedSlice(route.Object, "status", "parents")
+	accepted := false
+	for _, parent := range parents {
+		parentMap, ok := parent.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditions, _, _ := unstructured.NestedSlice(parentMap, "conditions")
+		for _, condition := range conditions {
+			conditionMap, ok := condition.(map[string]interface{})
+			if !ok || conditionMap["type"] != "Accepted" {
+				continue
+			}
+			if conditionMap["status"] != string(metav1.ConditionTrue) {
+				return fmt.Sprintf("HTTPRoute \"%s\" is not accepted: %v", name, conditionMap["message"]), deploymentv1.ConditionStatusFailed
+			}
+			accepted = true
+		}
+	}
+	if !accepted {
+		return fmt.Sprintf("HTTPRoute \"%s\" is waiting for the Gateway to accept it", name), deploymentv1.ConditionStatusUnKnown
+	}
+
+	return fmt.Sprintf("HTTPRoute \"%s\" is accepted", name), deploymentv1.ConditionStatusReady
+}
+
+func (r *SingleDeploymentReconciler) setRouteCondition(sd *deploymentv1.SingleDeployment, message, status string) {
+	reason := deploymentv1.ConditionReasonRouteUnavailable
+	if status == deploymentv1.ConditionStatusReady {
+		reason = deploymentv1.ConditionReasonRouteAvailable
+	}
+	r.setConditions(
+		&sd.Status,
+		deploymentv1.ConditionTypeRoute,
+		sd.Name,
+		message,
+		status,
+		reason,
+	)
+}
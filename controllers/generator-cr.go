@@ -1,12 +1,26 @@
 package controllers
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"path"
 	"strings"
+	"time"
 
+	"github.com/robfig/cron/v3"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	netv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
@@ -16,33 +30,164 @@ import (
 var IngressNginxClassName = "nginx"
 var IngressPathType = netv1.PathTypePrefix
 
+// HTTPRouteGroupVersionKind the HTTPRoute of Gateway API, its types are not vendored and it is managed as unstructured
+var HTTPRouteGroupVersionKind = schema.GroupVersionKind{
+	Group:   "gateway.networking.k8s.io",
+	Version: "v1beta1",
+	Kind:    "HTTPRoute",
+}
+
+// CertificateGroupVersionKind the Certificate of cert-manager, it is managed as unstructured like HTTPRoute
+var CertificateGroupVersionKind = schema.GroupVersionKind{
+	Group:   "cert-manager.io",
+	Version: "v1",
+	Kind:    "Certificate",
+}
+
+// The windows the last fired time of spec.schedule is searched in, a short one is tried first so the
+// frequent crons are not iterated over a long window
+var scheduleLookbacks = []time.Duration{time.Hour, 24 * time.Hour, 7 * 24 * time.Hour, 31 * 24 * time.Hour, 366 * 24 * time.Hour}
+
+var GitImage = "alpine/git:2.36.3"
+var KanikoImage = "gcr.io/kaniko-project/executor:v1.9.1"
+var BuildkitImage = "moby/buildkit:v0.10.6-rootless"
+var BuildBackoffLimit int32 = 1
+var BuildTTLSecondsAfterFinished int32 = 3600
+
+const (
+	BuildWorkspace     = "/workspace"
+	BuildContainerName = "build"
+)
+
+// ConfigHashAnnotation the hash of spec.configFiles on the pod template, the pods are restarted when it changes
+const ConfigHashAnnotation = "deployment.github.com/config-hash"
+
+// EnvFromHashAnnotation the hash of data of spec.envFrom on the pod template, the pods are restarted when it changes
+const EnvFromHashAnnotation = "deployment.github.com/env-from-hash"
+
+// ConfigFilesVolumeName the volume of the ConfigMap rendered from spec.configFiles
+const ConfigFilesVolumeName = "config-files"
+
+// TmpVolumeName the emptyDir mounted at /tmp of the containers with a read only root filesystem
+const TmpVolumeName = "tmp"
+
+// InstanceLabel names the instance of the pods, it is the only label of the pods not labeled `app: <instance>`,
+// e.g. the canary pods and the build pods
+const InstanceLabel = "deployment.github.com/instance"
+
+// The annotations of ingress-nginx sending a part of traffic to the canary Ingress
+const (
+	NginxCanaryAnnotation       = "nginx.ingress.kubernetes.io/canary"
+	NginxCanaryWeightAnnotation = "nginx.ingress.kubernetes.io/canary-weight"
+)
+
+// The annotations of cert-manager issuing the certificate of the Ingress
+const (
+	CertManagerIssuerAnnotation        = "cert-manager.io/issuer"
+	CertManagerClusterIssuerAnnotation = "cert-manager.io/cluster-issuer"
+)
+
+// NginxForceSSLRedirectAnnotation redirects HTTP to HTTPS by ingress-nginx
+const NginxForceSSLRedirectAnnotation = "nginx.ingress.kubernetes.io/force-ssl-redirect"
+
+// NginxRewriteTargetAnnotation the path ingress-nginx rewrites the requests of Ingress to
+const NginxRewriteTargetAnnotation = "nginx.ingress.kubernetes.io/rewrite-target"
+
+// RouteGroupLabel names the group of the Ingresses serving the routes of the rewrite targets other than the one of
+// the first route, the group is the name of the Ingress they are served beside
+const RouteGroupLabel = "deployment.github.com/route-group"
+
+// StorageDeletePolicyAnnotation marks the PersistentVolumeClaims which are kept after the owner is deleted
+const StorageDeletePolicyAnnotation = "deployment.github.com/delete-policy"
+
+// ImageBuilder adds the container which builds and pushes the image to the pod template of build job
+type ImageBuilder func(template *corev1.PodTemplateSpec, build *deploymentv1.Build, destination string)
+
+// ImageBuilders the builders can be chosen by spec.build.builder
+var ImageBuilders = map[string]ImageBuilder{
+	deploymentv1.BuilderKaniko:   withKanikoBuilder,
+	deploymentv1.BuilderBuildkit: withBuildkitBuilder,
+}
+
 func newDeployment(sd *deploymentv1.SingleDeployment) (*appsv1.Deployment, error) {
 	deploy := newBaseDeployment(sd.Name, sd.Namespace)
-	deploy.Spec.Replicas = &sd.Spec.Replicas
-	deploy.Spec.Template.Spec.Containers = []corev1.Container{
-		newBaseContainer(
-			sd.Name,
-			sd.Spec.Image,
-			sd.Spec.Port,
-			sd.Spec.Environments),
+	// The pods are labeled by the instance too, only the pods labeled by it are watched
+	deploy.Spec.Template.ObjectMeta.Labels = map[string]string{
+		"app":         sd.Name,
+		InstanceLabel: sd.Name,
+	}
+	replicas := instanceReplicas(sd)
+	deploy.Spec.Replicas = &replicas
+	if sd.Spec.Autoscaling != nil {
+		// Start from the lower limit, the HorizontalPodAutoscaler takes over the replicas after that
+		deploy.Spec.Replicas = autoscalingMinReplicas(sd)
+		if sd.Status.SuspendedReplicas != nil {
+			// Resume from the replicas before the instance was suspended
+			deploy.Spec.Replicas = sd.Status.SuspendedReplicas
+		}
+	}
+	if sd.Spec.Suspend {
+		// The Service, Ingress and node ports are kept, only the pods are removed
+		suspended := int32(0)
+		deploy.Spec.Replicas = &suspended
+	}
+
+	container := newBaseContainer(
+		sd.Name,
+		deploymentImage(sd),
+		newContainerPorts(sd),
+		sd.Spec.Environments)
+	command, args, err := sd.Spec.ContainerCommand(sd.Name)
+	if err != nil {
+		return nil, field.Invalid(field.NewPath("spec").Child("startCmd"), sd.Spec.StartCmd, err.Error())
 	}
+	withCommand(&container, command, args)
+	container.Resources = sd.Spec.Resources
+	withProbes(&container, sd.Spec.HealthCheck, mainPort(sd))
+	withStorage(&deploy, &container, sd)
+	withConfigFiles(&deploy, &container, sd)
+	withEnvFrom(&deploy, &container, sd)
+	deploy.Spec.Template.Spec.Containers = []corev1.Container{container}
+	withSidecars(&deploy, sd)
+	withSecurityContext(&deploy, sd.Spec.SecurityContext)
+	withScheduling(&deploy, sd.Spec.Scheduling)
+	if sd.Spec.ServiceAccount != nil {
+		deploy.Spec.Template.Spec.ServiceAccountName = serviceAccountName(sd)
+	}
+	withImagePullSecrets(&deploy, sd)
 
 	return &deploy, nil
 }
 
 func newService(sd *deploymentv1.SingleDeployment) (*corev1.Service, error) {
 	service := newBaseService(sd.Name, sd.Namespace)
-	servicePort := newBaseServicePort("http", "TCP", sd.Spec.Expose.ServicePort, sd.Spec.Port)
+	ports := sd.Spec.GetPorts()
+	servicePorts := make([]corev1.ServicePort, 0, len(ports))
+	for i := range ports {
+		servicePort := newBaseServicePort(ports[i].Name, string(ports[i].Protocol), ports[i].ServicePort, ports[i].ContainerPort)
+		servicePort.AppProtocol = ports[i].AppProtocol
+		if strings.ToLower(sd.Spec.Expose.Mode) == ServiceNodePort {
+			withNodePort(&servicePort, ports[i].NodePort)
+		}
+		servicePorts = append(servicePorts, servicePort)
+	}
 	switch strings.ToLower(sd.Spec.Expose.Mode) {
 	case ServiceNodePort:
 		service.Spec.Type = corev1.ServiceTypeNodePort
-		withNodePort(&servicePort, sd.Spec.Expose.NodePort)
-		service.Spec.Ports = []corev1.ServicePort{servicePort}
-	case ServiceIngress:
-		service.Spec.Ports = []corev1.ServicePort{servicePort}
+		service.Spec.Ports = servicePorts
+	case ServiceIngress, ServiceGateway, ServiceInternal:
+		service.Spec.Ports = servicePorts
+	case ServiceLoadBalancer:
+		service.Spec.Type = corev1.ServiceTypeLoadBalancer
+		service.Spec.Ports = servicePorts
+		service.Spec.LoadBalancerIP = sd.Spec.Expose.LoadBalancerIP
+		service.Spec.LoadBalancerSourceRanges = sd.Spec.Expose.SourceRanges
+		service.ObjectMeta.Annotations = sd.Spec.Expose.Annotations
 	default:
-		return nil, field.Invalid(field.NewPath("spec").Child("expose", "mode"), sd.Spec.Expose.Mode, "not be support. Must is `NodePort` or `Ingress`")
+		return nil, field.Invalid(field.NewPath("spec").Child("expose", "mode"), sd.Spec.Expose.Mode, "not be support. Must is `NodePort`, `Ingress`, `LoadBalancer`, `Gateway` or `Internal`")
 	}
+	service.Spec.ExternalTrafficPolicy = sd.Spec.Expose.ExternalTrafficPolicy
+	withActiveColor(&service, sd)
 
 	return &service, nil
 }
@@ -50,14 +195,550 @@ func newService(sd *deploymentv1.SingleDeployment) (*corev1.Service, error) {
 func newIngress(sd *deploymentv1.SingleDeployment) (*netv1.Ingress, error) {
 	ingress := newBaseIngress(sd.Name, sd.Namespace)
 
-	rule := newIngressBaseRule(sd.Spec.Expose.IngressDomain)
-	httpPath := newIngressRuleHttpBasePath(sd.Name, sd.Spec.Expose.ServicePort)
-	rule.HTTP.Paths = []netv1.HTTPIngressPath{httpPath}
-	ingress.Spec.Rules = []netv1.IngressRule{rule}
+	if len(sd.Spec.Expose.Routes) != 0 {
+		// The routes of other rewrite targets are served by newRouteGroupIngresses
+		withIngressRoutes(&ingress, sd, routeGroups(sd.Spec.Expose.Routes)[0])
+	} else {
+		rule := newIngressBaseRule(sd.Spec.Expose.IngressDomain)
+		ports := sd.Spec.GetPorts()
+		for i := range ports {
+			if ports[i].IngressPath == "" {
+				continue
+			}
+			httpPath := newIngressRuleHttpBasePath(sd.Name, ports[i].ServicePort)
+			withIngressPath(&httpPath, ports[i].IngressPath)
+			rule.HTTP.Paths = append(rule.HTTP.Paths, httpPath)
+		}
+		ingress.Spec.Rules = []netv1.IngressRule{rule}
+	}
+	withIngressTLS(&ingress, sd)
 
 	return &ingress, nil
 }
 
+// newRouteGroupIngresses builds an Ingress for each rewrite target of spec.expose.routes other than the one of the
+// first route, ingress-nginx applies the rewrite-target annotation to the whole Ingress
+func newRouteGroupIngresses(sd *deploymentv1.SingleDeployment) []*netv1.Ingress {
+	if strings.ToLower(sd.Spec.Expose.Mode) != ServiceIngress {
+		return nil
+	}
+	groups := routeGroups(sd.Spec.Expose.Routes)
+	if len(groups) < 2 {
+		return nil
+	}
+
+	ingresses := make([]*netv1.Ingress, 0, len(groups)-1)
+	for _, routes := range groups[1:] {
+		ingress := newBaseIngress(routeGroupName(sd.Name, routes[0].RewriteTarget), sd.Namespace)
+		ingress.ObjectMeta.Labels = map[string]string{RouteGroupLabel: sd.Name}
+		withIngressRoutes(&ingress, sd, routes)
+		withIngressTLS(&ingress, sd)
+		// cert-manager is asked for the certificate by the Ingress of instance only
+		delete(ingress.ObjectMeta.Annotations, CertManagerIssuerAnnotation)
+		delete(ingress.ObjectMeta.Annotations, CertManagerClusterIssuerAnnotation)
+		if len(ingress.ObjectMeta.Annotations) == 0 {
+			ingress.ObjectMeta.Annotations = nil
+		}
+		ingresses = append(ingresses, &ingress)
+	}
+
+	return ingresses
+}
+
+// newCertificate asks cert-manager for the certificate of the Ingress hosts, they are the hosts of
+// spec.expose.routes or spec.expose.ingressDomain
+func newCertificate(sd *deploymentv1.SingleDeployment) *unstructured.Unstructured {
+	certificate := newBaseCertificate(sd.Name, sd.Namespace)
+	tls := sd.Spec.Expose.TLS
+	if tls == nil || tls.IssuerRef == nil {
+		return certificate
+	}
+
+	hosts := sd.Spec.IngressHosts()
+	dnsNames := make([]interface{}, 0, len(hosts))
+	for _, host := range hosts {
+		dnsNames = append(dnsNames, host)
+	}
+	certificate.Object["spec"] = map[string]interface{}{
+		"secretName": tls.SecretName,
+		"dnsNames":   dnsNames,
+		"issuerRef": map[string]interface{}{
+			"name":  tls.IssuerRef.Name,
+			"kind":  tls.IssuerRef.Kind,
+			"group": CertificateGroupVersionKind.Group,
+		},
+	}
+
+	return certificate
+}
+
+// newHTTPRoute routes the ports with ingressPath like newIngress does, spec.expose.gateway.pathMatches
+// replaces the path of the first one
+func newHTTPRoute(sd *deploymentv1.SingleDeployment) *unstructured.Unstructured {
+	route := newBaseHTTPRoute(sd.Name, sd.Namespace)
+	gateway := sd.Spec.Expose.Gateway
+	if gateway == nil {
+		gateway = new(deploymentv1.GatewayExpose)
+	}
+
+	parentRef := map[string]interface{}{
+		"name": gateway.ParentRef.Name,
+	}
+	if gateway.ParentRef.Namespace != "" {
+		parentRef["namespace"] = gateway.ParentRef.Namespace
+	}
+	if gateway.ParentRef.SectionName != "" {
+		parentRef["sectionName"] = gateway.ParentRef.SectionName
+	}
+	spec := map[string]interface{}{
+		"parentRefs": []interface{}{parentRef},
+	}
+	if len(gateway.Hostnames) != 0 {
+		hostnames := make([]interface{}, 0, len(gateway.Hostnames))
+		for _, hostname := range gateway.Hostnames {
+			hostnames = append(hostnames, hostname)
+		}
+		spec["hostnames"] = hostnames
+	}
+
+	rules := []interface{}{}
+	ports := sd.Spec.GetPorts()
+	for i := range ports {
+		if ports[i].IngressPath == "" {
+			continue
+		}
+		var matches []interface{}
+		if len(rules) == 0 && len(gateway.PathMatches) != 0 {
+			for _, match := range gateway.PathMatches {
+				matches = append(matches, newHTTPRoutePathMatch(match.Type, match.Value))
+			}
+		} else {
+			matches = append(matches, newHTTPRoutePathMatch(deploymentv1.GatewayPathMatchPathPrefix, ports[i].IngressPath))
+		}
+		rules = append(rules, map[string]interface{}{
+			"matches": matches,
+			"backendRefs": []interface{}{
+				map[string]interface{}{
+					"name": sd.Name,
+					"port": int64(ports[i].ServicePort),
+				},
+			},
+		})
+	}
+	spec["rules"] = rules
+	route.Object["spec"] = spec
+
+	return route
+}
+
+func newBuildJob(sd *deploymentv1.SingleDeployment) (*batchv1.Job, error) {
+	build := sd.Spec.Build
+	buildPath := field.NewPath("spec").Child("build")
+
+	builderName := build.Builder
+	if builderName == "" {
+		builderName = deploymentv1.BuilderKaniko
+	}
+	builder, ok := ImageBuilders[builderName]
+	if !ok {
+		return nil, field.NotSupported(buildPath.Child("builder"), build.Builder, []string{deploymentv1.BuilderKaniko, deploymentv1.BuilderBuildkit})
+	}
+
+	hash := buildHash(build)
+	job := newBaseJob(buildJobName(sd.Name, hash), sd.Namespace, sd.Name)
+	podSpec := &job.Spec.Template.Spec
+
+	workspace := corev1.Volume{Name: "workspace"}
+	switch {
+	case build.Source.Git != nil:
+		workspace.EmptyDir = &corev1.EmptyDirVolumeSource{}
+		podSpec.InitContainers = []corev1.Container{newGitCloneContainer(build.Source.Git)}
+	case build.Source.ConfigMap != nil:
+		workspace.ConfigMap = &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: *build.Source.ConfigMap,
+		}
+	case build.Source.PersistentVolumeClaim != nil:
+		workspace.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: build.Source.PersistentVolumeClaim.ClaimName,
+			ReadOnly:  true,
+		}
+	default:
+		return nil, field.Required(buildPath.Child("source"), "Exactly one of `git`, `configMap` and `persistentVolumeClaim` must be set")
+	}
+	podSpec.Volumes = []corev1.Volume{workspace}
+	if build.PushSecret != "" {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: "docker-config",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: build.PushSecret,
+					Items: []corev1.KeyToPath{
+						{Key: corev1.DockerConfigJsonKey, Path: "config.json"},
+					},
+				},
+			},
+		})
+	}
+
+	builder(&job.Spec.Template, build, fmt.Sprintf("%s:build-%s", build.Registry, hash))
+
+	return &job, nil
+}
+
+func newHorizontalPodAutoscaler(sd *deploymentv1.SingleDeployment) *autoscalingv2.HorizontalPodAutoscaler {
+	autoscaling := sd.Spec.Autoscaling
+	hpa := newBaseHorizontalPodAutoscaler(sd.Name, sd.Namespace)
+	hpa.Spec.MinReplicas = autoscalingMinReplicas(sd)
+	hpa.Spec.MaxReplicas = autoscaling.MaxReplicas
+
+	if autoscaling.TargetCPUUtilizationPercentage != nil {
+		hpa.Spec.Metrics = append(hpa.Spec.Metrics,
+			newResourceMetric(corev1.ResourceCPU, *autoscaling.TargetCPUUtilizationPercentage))
+	}
+	if autoscaling.TargetMemoryUtilizationPercentage != nil {
+		hpa.Spec.Metrics = append(hpa.Spec.Metrics,
+			newResourceMetric(corev1.ResourceMemory, *autoscaling.TargetMemoryUtilizationPercentage))
+	}
+	hpa.Spec.Metrics = append(hpa.Spec.Metrics, autoscaling.Metrics...)
+
+	return &hpa
+}
+
+func newPodDisruptionBudget(sd *deploymentv1.SingleDeployment) *policyv1.PodDisruptionBudget {
+	pdb := newBasePodDisruptionBudget(sd.Name, sd.Namespace)
+	if sd.Spec.DisruptionBudget != nil &&
+		(sd.Spec.DisruptionBudget.MinAvailable != nil || sd.Spec.DisruptionBudget.MaxUnavailable != nil) {
+		pdb.Spec.MinAvailable = sd.Spec.DisruptionBudget.MinAvailable
+		pdb.Spec.MaxUnavailable = sd.Spec.DisruptionBudget.MaxUnavailable
+	} else {
+		maxUnavailable := intstr.FromInt(1)
+		pdb.Spec.MaxUnavailable = &maxUnavailable
+	}
+
+	return &pdb
+}
+
+func newServiceAccount(sd *deploymentv1.SingleDeployment) *corev1.ServiceAccount {
+	sa := corev1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ServiceAccount",
+			APIVersion: "v1",
+		},
+	}
+	sa.ObjectMeta.Name = serviceAccountName(sd)
+	sa.ObjectMeta.Namespace = sd.Namespace
+	sa.ObjectMeta.Labels = map[string]string{"app": sd.Name}
+
+	return &sa
+}
+
+func newRole(sd *deploymentv1.SingleDeployment) *rbacv1.Role {
+	role := rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Role",
+			APIVersion: "rbac.authorization.k8s.io/v1",
+		},
+	}
+	role.ObjectMeta.Name = sd.Name
+	role.ObjectMeta.Namespace = sd.Namespace
+	role.ObjectMeta.Labels = map[string]string{"app": sd.Name}
+	role.Rules = sd.Spec.ServiceAccount.Rules
+
+	return &role
+}
+
+func newRoleBinding(sd *deploymentv1.SingleDeployment) *rbacv1.RoleBinding {
+	binding := rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "RoleBinding",
+			APIVersion: "rbac.authorization.k8s.io/v1",
+		},
+	}
+	binding.ObjectMeta.Name = sd.Name
+	binding.ObjectMeta.Namespace = sd.Namespace
+	binding.ObjectMeta.Labels = map[string]string{"app": sd.Name}
+	binding.Subjects = []rbacv1.Subject{
+		{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      serviceAccountName(sd),
+			Namespace: sd.Namespace,
+		},
+	}
+	binding.RoleRef = rbacv1.RoleRef{
+		APIGroup: rbacv1.GroupName,
+		Kind:     "Role",
+		Name:     sd.Name,
+	}
+
+	return &binding
+}
+
+// newRegistrySecret builds the image pull Secret of spec.registryAuth from the username and password
+func newRegistrySecret(sd *deploymentv1.SingleDeployment, username, password []byte) (*corev1.Secret, error) {
+	type dockerConfigEntry struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+	config, err := json.Marshal(map[string]map[string]dockerConfigEntry{
+		"auths": {
+			sd.Spec.RegistryAuth.Server: {
+				Username: string(username),
+				Password: string(password),
+				Auth:     base64.StdEncoding.EncodeToString([]byte(string(username) + ":" + string(password))),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	secret := corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+	}
+	secret.ObjectMeta.Name = registrySecretName(sd.Name)
+	secret.ObjectMeta.Namespace = sd.Namespace
+	secret.ObjectMeta.Labels = map[string]string{"app": sd.Name}
+	secret.Type = corev1.SecretTypeDockerConfigJson
+	secret.Data = map[string][]byte{corev1.DockerConfigJsonKey: config}
+
+	return &secret, nil
+}
+
+func newConfigMap(sd *deploymentv1.SingleDeployment) *corev1.ConfigMap {
+	cm := newBaseConfigMap(configMapName(sd.Name), sd.Namespace, sd.Name)
+	cm.Data = configFilesData(sd.Spec.ConfigFiles)
+
+	return &cm
+}
+
+func newPersistentVolumeClaim(sd *deploymentv1.SingleDeployment, storage *deploymentv1.Storage) *corev1.PersistentVolumeClaim {
+	pvc := newBasePersistentVolumeClaim(storageClaimName(sd.Name, storage.Name), sd.Namespace, sd.Name)
+	if storage.DeletePolicy == deploymentv1.StorageDeletePolicyRetain {
+		pvc.ObjectMeta.Annotations = map[string]string{StorageDeletePolicyAnnotation: deploymentv1.StorageDeletePolicyRetain}
+	}
+
+	pvc.Spec.AccessModes = storage.AccessModes
+	if len(pvc.Spec.AccessModes) == 0 {
+		pvc.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+	pvc.Spec.StorageClassName = storage.StorageClassName
+	pvc.Spec.Resources.Requests = corev1.ResourceList{
+		corev1.ResourceStorage: storage.Size,
+	}
+
+	return &pvc
+}
+
+// newControllerRevision records the spec of instance as the revision, it is named by the hash of the spec
+func newControllerRevision(sd *deploymentv1.SingleDeployment, revision int64) (*appsv1.ControllerRevision, error) {
+	data, err := json.Marshal(revisionSpec(sd))
+	if err != nil {
+		return nil, err
+	}
+	hasher := fnv.New32a()
+	hasher.Write(data)
+
+	cr := newBaseControllerRevision(revisionName(sd.Name, fmt.Sprintf("%08x", hasher.Sum32())), sd.Namespace, sd.Name)
+	cr.Data = runtime.RawExtension{Raw: data}
+	cr.Revision = revision
+
+	return &cr, nil
+}
+
+// revisionSpec returns the spec recorded by revisions, the items managing the revisions are not recorded
+func revisionSpec(sd *deploymentv1.SingleDeployment) *deploymentv1.SingleDeploymentSpec {
+	spec := sd.Spec.DeepCopy()
+	spec.RevisionHistoryLimit = nil
+	spec.RollbackTo = nil
+	return spec
+}
+
+// newScheduleStatus returns the replicas of the latest entry of spec.schedule fired before now, and the next
+// change after now. The replicas are spec.replicas when no entry fired in a year
+func newScheduleStatus(sd *deploymentv1.SingleDeployment, now time.Time) (*deploymentv1.ScheduleStatus, error) {
+	status := &deploymentv1.ScheduleStatus{Replicas: sd.Spec.Replicas}
+
+	var lastFired, nextTime time.Time
+	var nextReplicas int32
+	for i := range sd.Spec.Schedule {
+		entry := &sd.Spec.Schedule[i]
+		schedule, err := entry.CronSchedule()
+		if err != nil {
+			return nil, field.Invalid(field.NewPath("spec", "schedule").Index(i).Child("cron"), entry.Cron, err.Error())
+		}
+		if fired := lastFiredTime(schedule, now); !fired.IsZero() && fired.After(lastFired) {
+			lastFired = fired
+			status.Replicas = entry.Replicas
+		}
+		if next := schedule.Next(now); !next.IsZero() && (nextTime.IsZero() || next.Before(nextTime)) {
+			nextTime = next
+			nextReplicas = entry.Replicas
+		}
+	}
+	if !nextTime.IsZero() {
+		// The time read back from the status is local, keep the same location so the status is not changed
+		next := metav1.NewTime(nextTime.Local())
+		status.NextTime = &next
+		status.NextReplicas = &nextReplicas
+	}
+
+	return status, nil
+}
+
+// newCanaryDeployment builds the Deployment running the pod template of spec beside the stable one
+func newCanaryDeployment(sd *deploymentv1.SingleDeployment) (*appsv1.Deployment, error) {
+	deploy, err := newParallelDeployment(sd, canaryName(sd.Name))
+	if err != nil {
+		return nil, err
+	}
+	deploy.Spec.Replicas = canaryReplicas(sd)
+
+	return deploy, nil
+}
+
+// newParallelDeployment builds the Deployment of name running the pod template of spec, its pods are labeled
+// `app: <name>` so they are not selected by the stable Deployment and Service
+func newParallelDeployment(sd *deploymentv1.SingleDeployment, name string) (*appsv1.Deployment, error) {
+	deploy, err := newDeployment(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	base := newBaseDeployment(name, sd.Namespace)
+	deploy.ObjectMeta = base.ObjectMeta
+	deploy.Spec.Selector = base.Spec.Selector
+	deploy.Spec.Template.ObjectMeta.Labels = map[string]string{
+		"app":         name,
+		InstanceLabel: sd.Name,
+	}
+
+	return deploy, nil
+}
+
+// newPreviewService builds the Service of blue/green preview pods, it is never exposed by node ports or load balancers
+func newPreviewService(sd *deploymentv1.SingleDeployment, color string) (*corev1.Service, error) {
+	service, err := newService(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	base := newBaseService(previewName(sd.Name), sd.Namespace)
+	service.ObjectMeta = base.ObjectMeta
+	service.Spec.Selector = map[string]string{"app": colorName(sd.Name, color)}
+	service.Spec.Type = ""
+	service.Spec.LoadBalancerIP = ""
+	service.Spec.LoadBalancerSourceRanges = nil
+	service.Spec.ExternalTrafficPolicy = ""
+	for i := range service.Spec.Ports {
+		service.Spec.Ports[i].NodePort = 0
+	}
+
+	return service, nil
+}
+
+// newPreviewIngresses builds the Ingresses of blue/green preview on spec.rollout.blueGreen.previewHost, the first
+// one previews the Ingress of instance and the others preview the route group Ingresses. The paths of all hosts
+// are merged into the rule of preview host, a path previewed by an earlier Ingress is not previewed again.
+func newPreviewIngresses(sd *deploymentv1.SingleDeployment) ([]*netv1.Ingress, error) {
+	ingress, err := newIngress(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	previews := []*netv1.Ingress{}
+	previewed := map[string]bool{}
+	for i, source := range append([]*netv1.Ingress{ingress}, newRouteGroupIngresses(sd)...) {
+		// The certificate is not issued for the preview host, so the TLS of source is not kept
+		preview := newBaseIngress(previewName(source.Name), sd.Namespace)
+		if i != 0 {
+			preview.ObjectMeta.Labels = map[string]string{RouteGroupLabel: previewName(sd.Name)}
+		}
+		// The requests of preview are rewritten the same way as the ones of source
+		if target, ok := source.Annotations[NginxRewriteTargetAnnotation]; ok {
+			withIngressAnnotation(&preview, NginxRewriteTargetAnnotation, target)
+		}
+
+		rule := newIngressBaseRule(sd.Spec.Rollout.BlueGreen.PreviewHost)
+		for _, sourceRule := range source.Spec.Rules {
+			if sourceRule.HTTP == nil {
+				continue
+			}
+			for _, httpPath := range sourceRule.HTTP.Paths {
+				key := httpPath.Path
+				if httpPath.PathType != nil {
+					key += " " + string(*httpPath.PathType)
+				}
+				if previewed[key] {
+					continue
+				}
+				previewed[key] = true
+				if backend := httpPath.Backend.Service; backend != nil {
+					backend.Name = previewName(sd.Name)
+				}
+				rule.HTTP.Paths = append(rule.HTTP.Paths, httpPath)
+			}
+		}
+		if i != 0 && len(rule.HTTP.Paths) == 0 {
+			continue
+		}
+		preview.Spec.Rules = []netv1.IngressRule{rule}
+		previews = append(previews, &preview)
+	}
+
+	return previews, nil
+}
+
+// newCanaryService builds the Service of canary pods
+func newCanaryService(sd *deploymentv1.SingleDeployment) (*corev1.Service, error) {
+	service, err := newService(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	base := newBaseService(canaryName(sd.Name), sd.Namespace)
+	service.ObjectMeta = base.ObjectMeta
+	service.Spec.Selector = base.Spec.Selector
+
+	return service, nil
+}
+
+// newCanaryIngress builds the Ingress which ingress-nginx sends the weight of traffic to the canary Service by
+func newCanaryIngress(sd *deploymentv1.SingleDeployment, weight int32) (*netv1.Ingress, error) {
+	ingress, err := newIngress(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	base := newBaseIngress(canaryName(sd.Name), sd.Namespace)
+	ingress.ObjectMeta = base.ObjectMeta
+	if len(sd.Spec.Expose.Routes) != 0 {
+		// ingress-nginx matches the canary paths with the ones of all the Ingresses of routes, and keeps their
+		// rewrite targets, so the routes of all groups are served by the one canary Ingress
+		ingress.Spec.Rules = nil
+		withIngressRoutes(ingress, sd, sd.Spec.Expose.Routes)
+	}
+	ingress.ObjectMeta.Annotations = map[string]string{
+		NginxCanaryAnnotation:       "true",
+		NginxCanaryWeightAnnotation: fmt.Sprint(weight),
+	}
+	for i := range ingress.Spec.Rules {
+		if ingress.Spec.Rules[i].HTTP == nil {
+			continue
+		}
+		for j := range ingress.Spec.Rules[i].HTTP.Paths {
+			if backend := ingress.Spec.Rules[i].HTTP.Paths[j].Backend.Service; backend != nil {
+				backend.Name = canaryName(sd.Name)
+			}
+		}
+	}
+
+	return ingress, nil
+}
+
 func newBaseDeployment(name string, namespace string) appsv1.Deployment {
 	d := appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{
@@ -106,15 +787,379 @@ func newBaseIngress(name string, namespace string) netv1.Ingress {
 	return i
 }
 
-func newBaseContainer(name, image string, port int32, envs []corev1.EnvVar) corev1.Container {
+func newBaseHTTPRoute(name string, namespace string) *unstructured.Unstructured {
+	route := new(unstructured.Unstructured)
+	route.SetGroupVersionKind(HTTPRouteGroupVersionKind)
+	route.SetName(name)
+	route.SetNamespace(namespace)
+
+	return route
+}
+
+func newBaseCertificate(name string, namespace string) *unstructured.Unstructured {
+	certificate := new(unstructured.Unstructured)
+	certificate.SetGroupVersionKind(CertificateGroupVersionKind)
+	certificate.SetName(name)
+	certificate.SetNamespace(namespace)
+
+	return certificate
+}
+
+func newHTTPRoutePathMatch(matchType, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"path": map[string]interface{}{
+			"type":  matchType,
+			"value": value,
+		},
+	}
+}
+
+// withIngressTLS serves the domain of Ingress with the certificate of spec.expose.tls, the Ingress is annotated
+// for cert-manager unless the controller creates the Certificate
+func withIngressTLS(i *netv1.Ingress, sd *deploymentv1.SingleDeployment) {
+	tls := sd.Spec.Expose.TLS
+	if tls == nil {
+		return
+	}
+
+	i.Spec.TLS = []netv1.IngressTLS{
+		{
+			Hosts:      sd.Spec.IngressHosts(),
+			SecretName: tls.SecretName,
+		},
+	}
+	if tls.IssuerRef != nil && !tls.CreateCertificate {
+		if tls.IssuerRef.Kind == deploymentv1.IssuerKindClusterIssuer {
+			withIngressAnnotation(i, CertManagerClusterIssuerAnnotation, tls.IssuerRef.Name)
+		} else {
+			withIngressAnnotation(i, CertManagerIssuerAnnotation, tls.IssuerRef.Name)
+		}
+	}
+	if tls.ForceSSLRedirect {
+		withIngressAnnotation(i, NginxForceSSLRedirectAnnotation, "true")
+	}
+}
+
+// withIngressRoutes adds a rule of the routes for each host, the paths are sent to the port of route. The routes
+// must have the same rewrite target
+func withIngressRoutes(i *netv1.Ingress, sd *deploymentv1.SingleDeployment, routes []deploymentv1.IngressRoute) {
+	ports := sd.Spec.GetPorts()
+	first := deploymentv1.FirstTCPPort(ports)
+	rules := map[string]int{}
+	for _, route := range routes {
+		servicePort := int32(0)
+		if first >= 0 {
+			servicePort = ports[first].ServicePort
+		}
+		for j := range ports {
+			if ports[j].Name == route.Port {
+				servicePort = ports[j].ServicePort
+			}
+		}
+		if servicePort == 0 {
+			// There is no TCP port to send the route to, it is rejected by the webhook
+			continue
+		}
+		httpPath := newIngressRuleHttpBasePath(sd.Name, servicePort)
+		withIngressPath(&httpPath, route.Path)
+		if route.PathType != "" {
+			pathType := netv1.PathType(route.PathType)
+			httpPath.PathType = &pathType
+		}
+
+		index, ok := rules[route.Host]
+		if !ok {
+			index = len(i.Spec.Rules)
+			rules[route.Host] = index
+			i.Spec.Rules = append(i.Spec.Rules, newIngressBaseRule(route.Host))
+		}
+		i.Spec.Rules[index].HTTP.Paths = append(i.Spec.Rules[index].HTTP.Paths, httpPath)
+
+		if route.RewriteTarget != "" {
+			withIngressAnnotation(i, NginxRewriteTargetAnnotation, route.RewriteTarget)
+		}
+	}
+}
+
+// routeGroups groups the routes by the rewrite target, the group of the first route comes first
+func routeGroups(routes []deploymentv1.IngressRoute) [][]deploymentv1.IngressRoute {
+	var groups [][]deploymentv1.IngressRoute
+	index := map[string]int{}
+	for _, route := range routes {
+		i, ok := index[route.RewriteTarget]
+		if !ok {
+			i = len(groups)
+			index[route.RewriteTarget] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], route)
+	}
+
+	return groups
+}
+
+// routeGroupName names the Ingress of the routes of rewrite target by its hash, so the name is kept when the
+// routes are reordered
+func routeGroupName(name, rewriteTarget string) string {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(rewriteTarget))
+	return fmt.Sprintf("%s-rewrite-%08x", name, hasher.Sum32())
+}
+
+func withIngressAnnotation(i *netv1.Ingress, key, value string) {
+	if i.ObjectMeta.Annotations == nil {
+		i.ObjectMeta.Annotations = map[string]string{}
+	}
+	i.ObjectMeta.Annotations[key] = value
+}
+
+func newBaseJob(name, namespace, owner string) batchv1.Job {
+	j := batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Job",
+			APIVersion: "batch/v1",
+		},
+	}
+	j.ObjectMeta.Name = name
+	j.ObjectMeta.Namespace = namespace
+
+	// Do not use the `app` label, the Service of owner selects pods by it
+	buildMap := map[string]string{"build": owner}
+	j.ObjectMeta.Labels = buildMap
+	j.Spec.Template.ObjectMeta.Labels = map[string]string{
+		"build":       owner,
+		InstanceLabel: owner,
+	}
+	j.Spec.BackoffLimit = &BuildBackoffLimit
+	j.Spec.TTLSecondsAfterFinished = &BuildTTLSecondsAfterFinished
+	j.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+
+	return j
+}
+
+func newBaseHorizontalPodAutoscaler(name, namespace string) autoscalingv2.HorizontalPodAutoscaler {
+	h := autoscalingv2.HorizontalPodAutoscaler{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "HorizontalPodAutoscaler",
+			APIVersion: "autoscaling/v2",
+		},
+	}
+	h.ObjectMeta.Name = name
+	h.ObjectMeta.Namespace = namespace
+	h.ObjectMeta.Labels = map[string]string{"app": name}
+	h.Spec.ScaleTargetRef = autoscalingv2.CrossVersionObjectReference{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Name:       name,
+	}
+
+	return h
+}
+
+func newBasePodDisruptionBudget(name, namespace string) policyv1.PodDisruptionBudget {
+	p := policyv1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PodDisruptionBudget",
+			APIVersion: "policy/v1",
+		},
+	}
+	p.ObjectMeta.Name = name
+	p.ObjectMeta.Namespace = namespace
+
+	nameMap := map[string]string{"app": name}
+	p.ObjectMeta.Labels = nameMap
+	p.Spec.Selector = &metav1.LabelSelector{}
+	p.Spec.Selector.MatchLabels = nameMap
+
+	return p
+}
+
+func newBaseConfigMap(name, namespace, owner string) corev1.ConfigMap {
+	c := corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: "v1",
+		},
+	}
+	c.ObjectMeta.Name = name
+	c.ObjectMeta.Namespace = namespace
+	c.ObjectMeta.Labels = map[string]string{"app": owner}
+
+	return c
+}
+
+func newBasePersistentVolumeClaim(name, namespace, owner string) corev1.PersistentVolumeClaim {
+	p := corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+		},
+	}
+	p.ObjectMeta.Name = name
+	p.ObjectMeta.Namespace = namespace
+	p.ObjectMeta.Labels = map[string]string{"app": owner}
+
+	return p
+}
+
+func newBaseControllerRevision(name, namespace, owner string) appsv1.ControllerRevision {
+	c := appsv1.ControllerRevision{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ControllerRevision",
+			APIVersion: "apps/v1",
+		},
+	}
+	c.ObjectMeta.Name = name
+	c.ObjectMeta.Namespace = namespace
+	c.ObjectMeta.Labels = map[string]string{"app": owner}
+
+	return c
+}
+
+func newGitCloneContainer(git *deploymentv1.GitSource) corev1.Container {
 	c := corev1.Container{}
-	c.Name = name
-	c.Image = image
-	c.Ports = []corev1.ContainerPort{
-		corev1.ContainerPort{
-			ContainerPort: port,
+	c.Name = "git-clone"
+	c.Image = GitImage
+	c.Command = append(append([]string{}, deploymentv1.ShellCommand...),
+		fmt.Sprintf(`git clone "$1" %[1]s && if [ -n "$2" ]; then git -C %[1]s checkout "$2"; fi`, BuildWorkspace))
+	c.Args = []string{c.Name, git.URL, git.Revision}
+	c.VolumeMounts = []corev1.VolumeMount{
+		{Name: "workspace", MountPath: BuildWorkspace},
+	}
+
+	return c
+}
+
+func withKanikoBuilder(template *corev1.PodTemplateSpec, build *deploymentv1.Build, destination string) {
+	c := corev1.Container{}
+	c.Name = BuildContainerName
+	c.Image = builderImage(build, KanikoImage)
+	c.Args = []string{
+		"--context=dir://" + buildContext(build),
+		"--dockerfile=" + path.Join(buildContext(build), buildDockerfile(build)),
+		"--destination=" + destination,
+		"--digest-file=" + corev1.TerminationMessagePathDefault,
+	}
+	c.VolumeMounts = buildVolumeMounts(build, "/kaniko/.docker")
+
+	template.Spec.Containers = append(template.Spec.Containers, c)
+}
+
+func withBuildkitBuilder(template *corev1.PodTemplateSpec, build *deploymentv1.Build, destination string) {
+	dockerfile := path.Join(buildContext(build), buildDockerfile(build))
+	uid := int64(1000)
+
+	c := corev1.Container{}
+	c.Name = BuildContainerName
+	c.Image = builderImage(build, BuildkitImage)
+	c.Command = []string{"buildctl-daemonless.sh"}
+	c.Args = []string{
+		"build",
+		"--frontend", "dockerfile.v0",
+		"--local", "context=" + buildContext(build),
+		"--local", "dockerfile=" + path.Dir(dockerfile),
+		"--opt", "filename=" + path.Base(dockerfile),
+		"--output", "type=image,name=" + destination + ",push=true",
+		"--metadata-file", corev1.TerminationMessagePathDefault,
+	}
+	// Rootless buildkit needs these to run without privileged
+	c.Env = []corev1.EnvVar{
+		{Name: "BUILDKITD_FLAGS", Value: "--oci-worker-no-process-sandbox"},
+	}
+	c.SecurityContext = &corev1.SecurityContext{
+		RunAsUser:  &uid,
+		RunAsGroup: &uid,
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeUnconfined,
 		},
 	}
+	c.VolumeMounts = buildVolumeMounts(build, "/home/user/.docker")
+
+	template.ObjectMeta.Annotations = map[string]string{
+		"container.apparmor.security.beta.kubernetes.io/" + BuildContainerName: "unconfined",
+	}
+	template.Spec.Containers = append(template.Spec.Containers, c)
+}
+
+func buildVolumeMounts(build *deploymentv1.Build, dockerConfigDir string) []corev1.VolumeMount {
+	workspace := corev1.VolumeMount{Name: "workspace", MountPath: BuildWorkspace}
+	if build.Source.PersistentVolumeClaim != nil {
+		workspace.SubPath = build.Source.PersistentVolumeClaim.SubPath
+	}
+	mounts := []corev1.VolumeMount{workspace}
+	if build.PushSecret != "" {
+		mounts = append(mounts, corev1.VolumeMount{Name: "docker-config", MountPath: dockerConfigDir, ReadOnly: true})
+	}
+
+	return mounts
+}
+
+func buildContext(build *deploymentv1.Build) string {
+	return path.Join(BuildWorkspace, build.ContextDir)
+}
+
+func buildDockerfile(build *deploymentv1.Build) string {
+	if build.Dockerfile == "" {
+		return "Dockerfile"
+	}
+	return build.Dockerfile
+}
+
+func builderImage(build *deploymentv1.Build, defaultImage string) string {
+	if build.BuilderImage == "" {
+		return defaultImage
+	}
+	return build.BuilderImage
+}
+
+// buildHash returns a short hash of build spec, a new build is started whenever it changes
+func buildHash(build *deploymentv1.Build) string {
+	data, _ := json.Marshal(build)
+	hasher := fnv.New32a()
+	hasher.Write(data)
+	return fmt.Sprintf("%08x", hasher.Sum32())
+}
+
+// buildJobName returns the name of build job. The job controller puts the name into a label, so keep it within 63 characters
+func buildJobName(name, hash string) string {
+	prefix := name
+	if len(prefix) > 63-len("-build-")-len(hash) {
+		prefix = prefix[:63-len("-build-")-len(hash)]
+	}
+	return fmt.Sprintf("%s-build-%s", strings.TrimSuffix(prefix, "-"), hash)
+}
+
+// parseBuildDigest gets the image digest from the termination message of builder.
+// kaniko writes the digest only, buildkit writes a json metadata file
+func parseBuildDigest(message string) (string, error) {
+	message = strings.TrimSpace(message)
+	if strings.HasPrefix(message, "{") {
+		metadata := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(message), &metadata); err != nil {
+			return "", err
+		}
+		message, _ = metadata["containerimage.digest"].(string)
+	}
+	if !strings.HasPrefix(message, "sha256:") {
+		return "", fmt.Errorf("no image digest found in build output %q", message)
+	}
+
+	return message, nil
+}
+
+// deploymentImage returns spec.image, or the built image when spec.image is empty
+func deploymentImage(sd *deploymentv1.SingleDeployment) string {
+	if sd.Spec.Image == "" && sd.Status.Build != nil {
+		return sd.Status.Build.Image
+	}
+	return sd.Spec.Image
+}
+
+func newBaseContainer(name, image string, ports []corev1.ContainerPort, envs []corev1.EnvVar) corev1.Container {
+	c := corev1.Container{}
+	c.Name = name
+	c.Image = image
+	c.Ports = ports
 	if envs != nil &&
 		len(envs) != 0 {
 		c.Env = envs
@@ -123,6 +1168,479 @@ func newBaseContainer(name, image string, port int32, envs []corev1.EnvVar) core
 	return c
 }
 
+func newContainerPorts(sd *deploymentv1.SingleDeployment) []corev1.ContainerPort {
+	if len(sd.Spec.Ports) == 0 {
+		// The legacy single port is unnamed
+		return []corev1.ContainerPort{
+			corev1.ContainerPort{
+				ContainerPort: sd.Spec.Port,
+			},
+		}
+	}
+
+	ports := sd.Spec.GetPorts()
+	containerPorts := make([]corev1.ContainerPort, 0, len(ports))
+	for i := range ports {
+		containerPorts = append(containerPorts, corev1.ContainerPort{
+			Name:          ports[i].Name,
+			ContainerPort: ports[i].ContainerPort,
+			Protocol:      ports[i].Protocol,
+		})
+	}
+
+	return containerPorts
+}
+
+// mainPort returns the port of probes, it is spec.port or the first TCP port. It is 0 when there is no TCP port,
+// the probes can not connect to UDP ports
+func mainPort(sd *deploymentv1.SingleDeployment) int32 {
+	ports := sd.Spec.GetPorts()
+	for _, port := range ports {
+		if port.ContainerPort == sd.Spec.Port && port.Protocol == corev1.ProtocolTCP {
+			return sd.Spec.Port
+		}
+	}
+	for _, port := range ports {
+		if port.Protocol == corev1.ProtocolTCP {
+			return port.ContainerPort
+		}
+	}
+	return 0
+}
+
+func withCommand(c *corev1.Container, command, args []string) {
+	if len(command) != 0 {
+		c.Command = command
+	}
+	if len(args) != 0 {
+		c.Args = args
+	}
+}
+
+// withStorage mounts the PersistentVolumeClaims of spec.storage. The pods of a ReadWriteOnce volume
+// can not run on two nodes at once, so the deployment is updated by recreating
+func withStorage(d *appsv1.Deployment, c *corev1.Container, sd *deploymentv1.SingleDeployment) {
+	for i := range sd.Spec.Storage {
+		storage := &sd.Spec.Storage[i]
+		volumeName := storageVolumeName(storage.Name)
+		d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: storageClaimName(sd.Name, storage.Name),
+				},
+			},
+		})
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+			Name:      volumeName,
+			MountPath: storage.MountPath,
+		})
+
+		if len(storage.AccessModes) == 0 {
+			d.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
+		}
+		for _, mode := range storage.AccessModes {
+			if mode == corev1.ReadWriteOnce || mode == corev1.ReadWriteOncePod {
+				d.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
+			}
+		}
+	}
+}
+
+func newResourceMetric(name corev1.ResourceName, utilization int32) autoscalingv2.MetricSpec {
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name: name,
+			Target: autoscalingv2.MetricTarget{
+				Type:               autoscalingv2.UtilizationMetricType,
+				AverageUtilization: &utilization,
+			},
+		},
+	}
+}
+
+// needDisruptionBudget returns true when the instance can run more than 1 replica. A budget of a
+// single replica instance would block the node drains
+func needDisruptionBudget(sd *deploymentv1.SingleDeployment) bool {
+	if sd.Spec.Suspend {
+		return false
+	}
+	if sd.Spec.Autoscaling != nil {
+		return sd.Spec.Autoscaling.MaxReplicas > 1
+	}
+	return instanceReplicas(sd) > 1
+}
+
+// withImagePullSecrets sets spec.imagePullSecrets and the Secret built from spec.registryAuth to pod template
+func withImagePullSecrets(d *appsv1.Deployment, sd *deploymentv1.SingleDeployment) {
+	podSpec := &d.Spec.Template.Spec
+	podSpec.ImagePullSecrets = append(podSpec.ImagePullSecrets, sd.Spec.ImagePullSecrets...)
+	if sd.Spec.RegistryAuth != nil {
+		podSpec.ImagePullSecrets = append(podSpec.ImagePullSecrets,
+			corev1.LocalObjectReference{Name: registrySecretName(sd.Name)})
+	}
+}
+
+func registrySecretName(name string) string {
+	return name + "-registry"
+}
+
+// serviceAccountName returns spec.serviceAccount.name, or the instance name if a created ServiceAccount has no name
+func serviceAccountName(sd *deploymentv1.SingleDeployment) string {
+	if sd.Spec.ServiceAccount.Name == "" && sd.Spec.ServiceAccount.Create {
+		return sd.Name
+	}
+	return sd.Spec.ServiceAccount.Name
+}
+
+// instanceReplicas returns the replicas applied by spec.schedule when it is set, otherwise spec.replicas
+func instanceReplicas(sd *deploymentv1.SingleDeployment) int32 {
+	if len(sd.Spec.Schedule) != 0 && sd.Status.Schedule != nil {
+		return sd.Status.Schedule.Replicas
+	}
+	return sd.Spec.Replicas
+}
+
+// lastFiredTime returns the latest time the schedule fired before or at now, it is zero when the schedule
+// did not fire in a year
+func lastFiredTime(schedule cron.Schedule, now time.Time) time.Time {
+	for _, lookback := range scheduleLookbacks {
+		var fired time.Time
+		for next := schedule.Next(now.Add(-lookback)); !next.IsZero() && !next.After(now); next = schedule.Next(next) {
+			fired = next
+		}
+		if !fired.IsZero() {
+			return fired
+		}
+	}
+	return time.Time{}
+}
+
+// autoscalingMinReplicas returns spec.autoscaling.minReplicas, or spec.replicas if it is empty
+func autoscalingMinReplicas(sd *deploymentv1.SingleDeployment) *int32 {
+	if sd.Spec.Autoscaling.MinReplicas != nil {
+		return sd.Spec.Autoscaling.MinReplicas
+	}
+	return &sd.Spec.Replicas
+}
+
+// withSidecars adds spec.sidecars behind the instance container and spec.initContainers to pod template
+func withSidecars(d *appsv1.Deployment, sd *deploymentv1.SingleDeployment) {
+	for i := range sd.Spec.Sidecars {
+		sidecar := sd.Spec.Sidecars[i].DeepCopy()
+		if sidecar.InheritEnvironments == nil || *sidecar.InheritEnvironments {
+			sidecar.Env = inheritEnvs(sd.Spec.Environments, sidecar.Env)
+		}
+		d.Spec.Template.Spec.Containers = append(d.Spec.Template.Spec.Containers, sidecar.Container)
+	}
+	for i := range sd.Spec.InitContainers {
+		d.Spec.Template.Spec.InitContainers = append(d.Spec.Template.Spec.InitContainers, *sd.Spec.InitContainers[i].DeepCopy())
+	}
+}
+
+// withSecurityContext sets spec.securityContext to the pod and its containers. The sidecars and init containers
+// keep their own settings, the unset ones are filled from spec.securityContext
+func withSecurityContext(d *appsv1.Deployment, securityContext *deploymentv1.SecurityContext) {
+	if securityContext == nil {
+		return
+	}
+
+	podSpec := &d.Spec.Template.Spec
+	podSpec.SecurityContext = securityContext.PodSecurityContext()
+	defaults := securityContext.ContainerSecurityContext()
+	// The seccomp profile is set to the pod, the containers inherit it
+	defaults.SeccompProfile = nil
+
+	containers := []*corev1.Container{}
+	for i := range podSpec.InitContainers {
+		containers = append(containers, &podSpec.InitContainers[i])
+	}
+	for i := range podSpec.Containers {
+		containers = append(containers, &podSpec.Containers[i])
+	}
+
+	// The instance container is the first one of podSpec.Containers
+	podSpec.Containers[0].SecurityContext = defaults
+	tmpMounted := false
+	for _, c := range containers {
+		c.SecurityContext = mergeSecurityContext(c.SecurityContext, defaults)
+		if c.SecurityContext.ReadOnlyRootFilesystem != nil && *c.SecurityContext.ReadOnlyRootFilesystem && !hasMountPath(c, "/tmp") {
+			c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: TmpVolumeName, MountPath: "/tmp"})
+			tmpMounted = true
+		}
+	}
+	if tmpMounted {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name:         TmpVolumeName,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+	}
+}
+
+// mergeSecurityContext fills the unset items of own with defaults
+func mergeSecurityContext(own, defaults *corev1.SecurityContext) *corev1.SecurityContext {
+	if own == nil {
+		return defaults.DeepCopy()
+	}
+
+	merged := own.DeepCopy()
+	if merged.RunAsNonRoot == nil {
+		merged.RunAsNonRoot = defaults.RunAsNonRoot
+	}
+	if merged.RunAsUser == nil {
+		merged.RunAsUser = defaults.RunAsUser
+	}
+	if merged.RunAsGroup == nil {
+		merged.RunAsGroup = defaults.RunAsGroup
+	}
+	if merged.ReadOnlyRootFilesystem == nil {
+		merged.ReadOnlyRootFilesystem = defaults.ReadOnlyRootFilesystem
+	}
+	if merged.AllowPrivilegeEscalation == nil {
+		merged.AllowPrivilegeEscalation = defaults.AllowPrivilegeEscalation
+	}
+	if merged.Capabilities == nil && defaults.Capabilities != nil {
+		merged.Capabilities = defaults.Capabilities.DeepCopy()
+	}
+
+	return merged
+}
+
+func hasMountPath(c *corev1.Container, mountPath string) bool {
+	for i := range c.VolumeMounts {
+		if path.Clean(c.VolumeMounts[i].MountPath) == mountPath {
+			return true
+		}
+	}
+	return false
+}
+
+// inheritEnvs puts the inherited envs before the own envs, an own env overrides the inherited one of same name
+func inheritEnvs(inherited, own []corev1.EnvVar) []corev1.EnvVar {
+	if len(inherited) == 0 {
+		return own
+	}
+
+	names := make(map[string]bool, len(own))
+	for i := range own {
+		names[own[i].Name] = true
+	}
+	envs := make([]corev1.EnvVar, 0, len(inherited)+len(own))
+	for i := range inherited {
+		if !names[inherited[i].Name] {
+			envs = append(envs, inherited[i])
+		}
+	}
+
+	return append(envs, own...)
+}
+
+// withScheduling sets the scheduling constraints of spec.scheduling, the spread shortcuts select the pods by the `app` label
+func withScheduling(d *appsv1.Deployment, scheduling *deploymentv1.Scheduling) {
+	if scheduling == nil {
+		return
+	}
+
+	podSpec := &d.Spec.Template.Spec
+	podSpec.NodeSelector = scheduling.NodeSelector
+	podSpec.Tolerations = scheduling.Tolerations
+	podSpec.Affinity = scheduling.Affinity
+	if scheduling.SpreadAcrossZones {
+		podSpec.TopologySpreadConstraints = append(podSpec.TopologySpreadConstraints,
+			newTopologySpreadConstraint(corev1.LabelTopologyZone, d.Spec.Selector.DeepCopy()))
+	}
+	if scheduling.SpreadAcrossNodes {
+		podSpec.TopologySpreadConstraints = append(podSpec.TopologySpreadConstraints,
+			newTopologySpreadConstraint(corev1.LabelHostname, d.Spec.Selector.DeepCopy()))
+	}
+}
+
+// newTopologySpreadConstraint spreads the pods as evenly as possible, but still schedules them when it can not
+func newTopologySpreadConstraint(topologyKey string, selector *metav1.LabelSelector) corev1.TopologySpreadConstraint {
+	return corev1.TopologySpreadConstraint{
+		MaxSkew:           1,
+		TopologyKey:       topologyKey,
+		WhenUnsatisfiable: corev1.ScheduleAnyway,
+		LabelSelector:     selector,
+	}
+}
+
+// withConfigFiles mounts every file of spec.configFiles from the ConfigMap by subPath, and
+// annotates the pod template with the hash of their content to restart the pods on changes
+func withConfigFiles(d *appsv1.Deployment, c *corev1.Container, sd *deploymentv1.SingleDeployment) {
+	if len(sd.Spec.ConfigFiles) == 0 {
+		return
+	}
+
+	d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, corev1.Volume{
+		Name: ConfigFilesVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configMapName(sd.Name)},
+			},
+		},
+	})
+	for i := range sd.Spec.ConfigFiles {
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+			Name:      ConfigFilesVolumeName,
+			MountPath: sd.Spec.ConfigFiles[i].Path,
+			SubPath:   sd.Spec.ConfigFiles[i].Key(),
+			ReadOnly:  true,
+		})
+	}
+
+	if d.Spec.Template.ObjectMeta.Annotations == nil {
+		d.Spec.Template.ObjectMeta.Annotations = map[string]string{}
+	}
+	d.Spec.Template.ObjectMeta.Annotations[ConfigHashAnnotation] = configFilesHash(sd.Spec.ConfigFiles)
+}
+
+// withEnvFrom sets spec.envFrom, and annotates the pod template with the hash of their data
+// recorded in status to restart the pods on changes
+func withEnvFrom(d *appsv1.Deployment, c *corev1.Container, sd *deploymentv1.SingleDeployment) {
+	if len(sd.Spec.EnvFrom) == 0 {
+		return
+	}
+
+	c.EnvFrom = sd.Spec.EnvFrom
+	if sd.Status.EnvFromHash != "" {
+		if d.Spec.Template.ObjectMeta.Annotations == nil {
+			d.Spec.Template.ObjectMeta.Annotations = map[string]string{}
+		}
+		d.Spec.Template.ObjectMeta.Annotations[EnvFromHashAnnotation] = sd.Status.EnvFromHash
+	}
+}
+
+func configFilesData(files []deploymentv1.ConfigFile) map[string]string {
+	data := make(map[string]string, len(files))
+	for i := range files {
+		data[files[i].Key()] = files[i].Content
+	}
+	return data
+}
+
+// templateHash identifies a pod template, it is the revision of rollout
+func templateHash(template *corev1.PodTemplateSpec) string {
+	data, _ := json.Marshal(template)
+	hasher := fnv.New32a()
+	hasher.Write(data)
+	return fmt.Sprintf("%08x", hasher.Sum32())
+}
+
+// withActiveColor selects the parallel Deployment of blue/green after the Service is switched to it
+func withActiveColor(s *corev1.Service, sd *deploymentv1.SingleDeployment) {
+	rollout := sd.Status.Rollout
+	if sd.Spec.Rollout == nil ||
+		sd.Spec.Rollout.BlueGreen == nil ||
+		rollout == nil ||
+		rollout.Color == "" {
+		return
+	}
+	if rollout.Phase == deploymentv1.RolloutPhasePromoted ||
+		rollout.Phase == deploymentv1.RolloutPhasePromoting {
+		s.Spec.Selector = map[string]string{"app": colorName(sd.Name, rollout.Color)}
+	}
+}
+
+func colorName(name, color string) string {
+	return name + "-" + color
+}
+
+func previewName(name string) string {
+	return name + "-preview"
+}
+
+func revisionName(name, hash string) string {
+	return name + "-" + hash
+}
+
+func canaryName(name string) string {
+	return name + "-canary"
+}
+
+// canaryReplicas returns spec.rollout.canary.replicas, it is 1 by default
+func canaryReplicas(sd *deploymentv1.SingleDeployment) *int32 {
+	replicas := int32(1)
+	if sd.Spec.Rollout != nil &&
+		sd.Spec.Rollout.Canary != nil &&
+		sd.Spec.Rollout.Canary.Replicas != nil {
+		replicas = *sd.Spec.Rollout.Canary.Replicas
+	}
+	return &replicas
+}
+
+func configFilesHash(files []deploymentv1.ConfigFile) string {
+	data, _ := json.Marshal(configFilesData(files))
+	hasher := fnv.New32a()
+	hasher.Write(data)
+	return fmt.Sprintf("%08x", hasher.Sum32())
+}
+
+func configMapName(name string) string {
+	return name + "-config"
+}
+
+func storageClaimName(name, storage string) string {
+	return fmt.Sprintf("%s-%s", name, storage)
+}
+
+func storageVolumeName(storage string) string {
+	return "storage-" + storage
+}
+
+func withProbes(c *corev1.Container, healthCheck *deploymentv1.HealthCheck, port int32) {
+	if healthCheck == nil {
+		healthCheck = &deploymentv1.HealthCheck{}
+	}
+
+	c.LivenessProbe = newProbe(healthCheck.Liveness, port)
+	c.StartupProbe = newProbe(healthCheck.Startup, port)
+	c.ReadinessProbe = newProbe(healthCheck.Readiness, port)
+	if healthCheck.Readiness == nil && port != 0 {
+		// The instance is not ready until it accepts connections
+		c.ReadinessProbe = newProbe(&deploymentv1.Probe{TCPSocket: &deploymentv1.TCPSocketProbe{}}, port)
+	}
+}
+
+func newProbe(probe *deploymentv1.Probe, port int32) *corev1.Probe {
+	if probe == nil {
+		return nil
+	}
+
+	p := &corev1.Probe{}
+	switch {
+	case probe.HTTPGet != nil:
+		p.HTTPGet = &corev1.HTTPGetAction{
+			Path:   probe.HTTPGet.Path,
+			Port:   intstr.FromInt(int(probePort(probe.HTTPGet.Port, port))),
+			Scheme: corev1.URIScheme(strings.ToUpper(probe.HTTPGet.Scheme)),
+		}
+	case probe.TCPSocket != nil:
+		p.TCPSocket = &corev1.TCPSocketAction{
+			Port: intstr.FromInt(int(probePort(probe.TCPSocket.Port, port))),
+		}
+	case probe.Exec != nil:
+		p.Exec = &corev1.ExecAction{
+			Command: probe.Exec.Command,
+		}
+	}
+	p.InitialDelaySeconds = probe.InitialDelaySeconds
+	p.PeriodSeconds = probe.PeriodSeconds
+	p.TimeoutSeconds = probe.TimeoutSeconds
+	p.SuccessThreshold = probe.SuccessThreshold
+	p.FailureThreshold = probe.FailureThreshold
+
+	return p
+}
+
+func probePort(probePort, port int32) int32 {
+	if probePort == 0 {
+		return port
+	}
+	return probePort
+}
+
 func newBaseServicePort(name, protocol string, port, targetPort int32) corev1.ServicePort {
 	sp := corev1.ServicePort{}
 	sp.Name = name
@@ -147,6 +1665,10 @@ func newIngressBaseRule(domainHost string) netv1.IngressRule {
 	return r
 }
 
+func withIngressPath(p *netv1.HTTPIngressPath, path string) {
+	p.Path = path
+}
+
 func newIngressRuleHttpBasePath(backendServiceName string, portNumber int32) netv1.HTTPIngressPath {
 	p := netv1.HTTPIngressPath{}
 	p.Path = "/"
@@ -1,16 +1,24 @@
 package controllers
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	netv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/yaml"
 )
 
@@ -66,6 +74,20 @@ func makeService(filename string) *corev1.Service {
 	return svc
 }
 
+func makeUnstructured(filename string) *unstructured.Unstructured {
+	content, err := readFile(filename)
+	if err != nil {
+		panic(err)
+	}
+
+	u := new(unstructured.Unstructured)
+	if err := yaml.Unmarshal(content, u); err != nil {
+		panic(err)
+	}
+
+	return u
+}
+
 func makeIngress(filename string) *netv1.Ingress {
 	content, err := readFile(filename)
 	if err != nil {
@@ -80,6 +102,118 @@ func makeIngress(filename string) *netv1.Ingress {
 	return ig
 }
 
+func makeJob(filename string) *batchv1.Job {
+	content, err := readFile(filename)
+	if err != nil {
+		panic(err)
+	}
+
+	j := new(batchv1.Job)
+	if err := yaml.Unmarshal(content, j); err != nil {
+		panic(err)
+	}
+
+	return j
+}
+
+func makeHorizontalPodAutoscaler(filename string) *autoscalingv2.HorizontalPodAutoscaler {
+	content, err := readFile(filename)
+	if err != nil {
+		panic(err)
+	}
+
+	hpa := new(autoscalingv2.HorizontalPodAutoscaler)
+	if err := yaml.Unmarshal(content, hpa); err != nil {
+		panic(err)
+	}
+
+	return hpa
+}
+
+func makePodDisruptionBudget(filename string) *policyv1.PodDisruptionBudget {
+	content, err := readFile(filename)
+	if err != nil {
+		panic(err)
+	}
+
+	pdb := new(policyv1.PodDisruptionBudget)
+	if err := yaml.Unmarshal(content, pdb); err != nil {
+		panic(err)
+	}
+
+	return pdb
+}
+
+func makeRole(filename string) *rbacv1.Role {
+	content, err := readFile(filename)
+	if err != nil {
+		panic(err)
+	}
+
+	role := new(rbacv1.Role)
+	if err := yaml.Unmarshal(content, role); err != nil {
+		panic(err)
+	}
+
+	return role
+}
+
+func makeRoleBinding(filename string) *rbacv1.RoleBinding {
+	content, err := readFile(filename)
+	if err != nil {
+		panic(err)
+	}
+
+	binding := new(rbacv1.RoleBinding)
+	if err := yaml.Unmarshal(content, binding); err != nil {
+		panic(err)
+	}
+
+	return binding
+}
+
+func makeSecret(filename string) *corev1.Secret {
+	content, err := readFile(filename)
+	if err != nil {
+		panic(err)
+	}
+
+	secret := new(corev1.Secret)
+	if err := yaml.Unmarshal(content, secret); err != nil {
+		panic(err)
+	}
+
+	return secret
+}
+
+func makeConfigMap(filename string) *corev1.ConfigMap {
+	content, err := readFile(filename)
+	if err != nil {
+		panic(err)
+	}
+
+	cm := new(corev1.ConfigMap)
+	if err := yaml.Unmarshal(content, cm); err != nil {
+		panic(err)
+	}
+
+	return cm
+}
+
+func makePersistentVolumeClaim(filename string) *corev1.PersistentVolumeClaim {
+	content, err := readFile(filename)
+	if err != nil {
+		panic(err)
+	}
+
+	pvc := new(corev1.PersistentVolumeClaim)
+	if err := yaml.Unmarshal(content, pvc); err != nil {
+		panic(err)
+	}
+
+	return pvc
+}
+
 func Test_newDeployment(t *testing.T) {
 	type args struct {
 		sd *deploymentv1.SingleDeployment
@@ -115,6 +249,150 @@ func Test_newDeployment(t *testing.T) {
 			want:    makeDeployment("deployment_except_nodeport_envs.yaml"),
 			wantErr: false,
 		},
+		{
+			name: "Test case create nodeport mode for deployment with quoted start command",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_cmd.yaml"),
+			},
+			want:    makeDeployment("deployment_except_nodeport_cmd.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create nodeport mode for deployment with shell start command",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_shell.yaml"),
+			},
+			want:    makeDeployment("deployment_except_nodeport_shell.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create nodeport mode for deployment with unterminated quote",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_bad_cmd.yaml"),
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Test case create nodeport mode for deployment with resources",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_resources.yaml"),
+			},
+			want:    makeDeployment("deployment_except_nodeport_resources.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create ingress mode for deployment with probes",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_probes.yaml"),
+			},
+			want:    makeDeployment("deployment_except_ingress_probes.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create ingress mode for deployment with named ports",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_ports.yaml"),
+			},
+			want:    makeDeployment("deployment_except_ingress_ports.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create deployment with built image",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_build_git.yaml"),
+			},
+			want:    makeDeployment("deployment_except_build_git.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create nodeport mode for deployment with storage",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_storage.yaml"),
+			},
+			want:    makeDeployment("deployment_except_nodeport_storage.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create ingress mode for deployment with config files",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_config.yaml"),
+			},
+			want:    makeDeployment("deployment_except_ingress_config.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create nodeport mode for deployment with envFrom",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_envfrom.yaml"),
+			},
+			want:    makeDeployment("deployment_except_nodeport_envfrom.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create nodeport mode for deployment with autoscaling",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_autoscaling.yaml"),
+			},
+			want:    makeDeployment("deployment_except_nodeport_autoscaling.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create ingress mode for suspended deployment",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_suspend.yaml"),
+			},
+			want:    makeDeployment("deployment_except_ingress_suspend.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create nodeport mode for deployment resumed with autoscaling",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_resume.yaml"),
+			},
+			want:    makeDeployment("deployment_except_nodeport_resume.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create nodeport mode for deployment with scheduling",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_scheduling.yaml"),
+			},
+			want:    makeDeployment("deployment_except_nodeport_scheduling.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create nodeport mode for deployment with sidecars",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_sidecars.yaml"),
+			},
+			want:    makeDeployment("deployment_except_nodeport_sidecars.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create nodeport mode for deployment with service account",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_serviceaccount.yaml"),
+			},
+			want:    makeDeployment("deployment_except_nodeport_serviceaccount.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create nodeport mode for deployment with registry credentials",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_registry.yaml"),
+			},
+			want:    makeDeployment("deployment_except_nodeport_registry.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create nodeport mode for deployment with security context",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_security.yaml"),
+			},
+			want:    makeDeployment("deployment_except_nodeport_security.yaml"),
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -157,6 +435,54 @@ func Test_newService(t *testing.T) {
 			want:    makeService("service_except_nodeport.yaml"),
 			wantErr: false,
 		},
+		{
+			name: "Test case create ingress mode for service with named ports",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_ports.yaml"),
+			},
+			want:    makeService("service_except_ingress_ports.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create nodeport mode for service with named ports",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_ports.yaml"),
+			},
+			want:    makeService("service_except_nodeport_ports.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create ingress mode for service switched to blue/green preview",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_bluegreen.yaml"),
+			},
+			want:    makeService("service_except_ingress_bluegreen.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create loadbalancer mode for service",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_loadbalancer.yaml"),
+			},
+			want:    makeService("service_except_loadbalancer.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create internal mode for service",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_internal.yaml"),
+			},
+			want:    makeService("service_except_internal.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create none mode for service",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_none.yaml"),
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -191,6 +517,46 @@ func Test_newIngress(t *testing.T) {
 			want:    makeIngress("ingress_except_ingress.yaml"),
 			wantErr: false,
 		},
+		{
+			name: "Test case create ingress mode for ingress with named ports",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_ports.yaml"),
+			},
+			want:    makeIngress("ingress_except_ingress_ports.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create ingress mode for ingress with cert-manager annotation",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_tls.yaml"),
+			},
+			want:    makeIngress("ingress_except_ingress_tls.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create ingress mode for ingress with certificate",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_certificate.yaml"),
+			},
+			want:    makeIngress("ingress_except_ingress_certificate.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create ingress mode for ingress with routes",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_routes.yaml"),
+			},
+			want:    makeIngress("ingress_except_ingress_routes.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create ingress mode for ingress with routes of rewrite targets",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_rewrite.yaml"),
+			},
+			want:    makeIngress("ingress_except_ingress_rewrite.yaml"),
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -205,3 +571,697 @@ func Test_newIngress(t *testing.T) {
 		})
 	}
 }
+
+func Test_newRouteGroupIngresses(t *testing.T) {
+	type args struct {
+		sd *deploymentv1.SingleDeployment
+	}
+	tests := []struct {
+		name string
+		args args
+		want []*netv1.Ingress
+	}{
+		{
+			name: "Test case create ingresses of routes with one rewrite target",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_routes.yaml"),
+			},
+			want: nil,
+		},
+		{
+			name: "Test case create ingresses of routes of rewrite targets",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_rewrite.yaml"),
+			},
+			want: []*netv1.Ingress{makeIngress("ingress_except_ingress_rewrite_group.yaml")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newRouteGroupIngresses(tt.args.sd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newRouteGroupIngresses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newCertificate(t *testing.T) {
+	type args struct {
+		sd *deploymentv1.SingleDeployment
+	}
+	tests := []struct {
+		name string
+		args args
+		want *unstructured.Unstructured
+	}{
+		{
+			name: "Test case create ingress mode for certificate",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_certificate.yaml"),
+			},
+			want: makeUnstructured("certificate_except_ingress_certificate.yaml"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newCertificate(tt.args.sd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newCertificate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newHTTPRoute(t *testing.T) {
+	type args struct {
+		sd *deploymentv1.SingleDeployment
+	}
+	tests := []struct {
+		name string
+		args args
+		want *unstructured.Unstructured
+	}{
+		{
+			name: "Test case create gateway mode for httproute",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_gateway.yaml"),
+			},
+			want: makeUnstructured("httproute_except_gateway.yaml"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newHTTPRoute(tt.args.sd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newHTTPRoute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newBuildJob(t *testing.T) {
+	type args struct {
+		sd *deploymentv1.SingleDeployment
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *batchv1.Job
+		wantErr bool
+	}{
+		{
+			name: "Test case create kaniko build job from git",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_build_git.yaml"),
+			},
+			want:    makeJob("job_except_build_git.yaml"),
+			wantErr: false,
+		},
+		{
+			name: "Test case create buildkit build job from configmap",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_build_configmap.yaml"),
+			},
+			want:    makeJob("job_except_build_configmap.yaml"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newBuildJob(tt.args.sd)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newBuildJob() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newBuildJob() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newPersistentVolumeClaim(t *testing.T) {
+	sd := makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_storage.yaml")
+	type args struct {
+		sd      *deploymentv1.SingleDeployment
+		storage *deploymentv1.Storage
+	}
+	tests := []struct {
+		name string
+		args args
+		want *corev1.PersistentVolumeClaim
+	}{
+		{
+			name: "Test case create retained pvc",
+			args: args{
+				sd:      sd,
+				storage: &sd.Spec.Storage[0],
+			},
+			want: makePersistentVolumeClaim("pvc_except_nodeport_storage_data.yaml"),
+		},
+		{
+			name: "Test case create pvc deleted with instance",
+			args: args{
+				sd:      sd,
+				storage: &sd.Spec.Storage[1],
+			},
+			want: makePersistentVolumeClaim("pvc_except_nodeport_storage_cache.yaml"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newPersistentVolumeClaim(tt.args.sd, tt.args.storage); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newPersistentVolumeClaim() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newConfigMap(t *testing.T) {
+	type args struct {
+		sd *deploymentv1.SingleDeployment
+	}
+	tests := []struct {
+		name string
+		args args
+		want *corev1.ConfigMap
+	}{
+		{
+			name: "Test case create configmap of config files",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_config.yaml"),
+			},
+			want: makeConfigMap("configmap_except_ingress_config.yaml"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newConfigMap(tt.args.sd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newConfigMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newHorizontalPodAutoscaler(t *testing.T) {
+	type args struct {
+		sd *deploymentv1.SingleDeployment
+	}
+	tests := []struct {
+		name string
+		args args
+		want *autoscalingv2.HorizontalPodAutoscaler
+	}{
+		{
+			name: "Test case create hpa with resource and custom metrics",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_autoscaling.yaml"),
+			},
+			want: makeHorizontalPodAutoscaler("hpa_except_nodeport_autoscaling.yaml"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newHorizontalPodAutoscaler(tt.args.sd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newHorizontalPodAutoscaler() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newPodDisruptionBudget(t *testing.T) {
+	type args struct {
+		sd *deploymentv1.SingleDeployment
+	}
+	tests := []struct {
+		name string
+		args args
+		want *policyv1.PodDisruptionBudget
+	}{
+		{
+			name: "Test case create default pdb",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport.yaml"),
+			},
+			want: makePodDisruptionBudget("pdb_except_nodeport.yaml"),
+		},
+		{
+			name: "Test case create pdb with minAvailable",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_pdb.yaml"),
+			},
+			want: makePodDisruptionBudget("pdb_except_nodeport_pdb.yaml"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newPodDisruptionBudget(tt.args.sd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newPodDisruptionBudget() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newRole(t *testing.T) {
+	type args struct {
+		sd *deploymentv1.SingleDeployment
+	}
+	tests := []struct {
+		name string
+		args args
+		want *rbacv1.Role
+	}{
+		{
+			name: "Test case create role of service account rules",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_serviceaccount.yaml"),
+			},
+			want: makeRole("role_except_nodeport_serviceaccount.yaml"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newRole(tt.args.sd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newRole() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newRoleBinding(t *testing.T) {
+	type args struct {
+		sd *deploymentv1.SingleDeployment
+	}
+	tests := []struct {
+		name string
+		args args
+		want *rbacv1.RoleBinding
+	}{
+		{
+			name: "Test case create role binding of created service account",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_serviceaccount.yaml"),
+			},
+			want: makeRoleBinding("rolebinding_except_nodeport_serviceaccount.yaml"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newRoleBinding(tt.args.sd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newRoleBinding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newRegistrySecret(t *testing.T) {
+	type args struct {
+		sd       *deploymentv1.SingleDeployment
+		username []byte
+		password []byte
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.Secret
+		wantErr bool
+	}{
+		{
+			name: "Test case create image pull secret of registry auth",
+			args: args{
+				sd:       makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_registry.yaml"),
+				username: []byte("admin"),
+				password: []byte("secret"),
+			},
+			want:    makeSecret("secret_except_nodeport_registry.yaml"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newRegistrySecret(tt.args.sd, tt.args.username, tt.args.password)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newRegistrySecret() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newRegistrySecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newCanaryDeployment(t *testing.T) {
+	type args struct {
+		sd *deploymentv1.SingleDeployment
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *appsv1.Deployment
+		wantErr bool
+	}{
+		{
+			name: "Test case create canary deployment of ingress mode",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_canary.yaml"),
+			},
+			want:    makeDeployment("deployment_except_ingress_canary.yaml"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newCanaryDeployment(tt.args.sd)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newCanaryDeployment() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newCanaryDeployment() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newCanaryService(t *testing.T) {
+	type args struct {
+		sd *deploymentv1.SingleDeployment
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.Service
+		wantErr bool
+	}{
+		{
+			name: "Test case create canary service of ingress mode",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_canary.yaml"),
+			},
+			want:    makeService("service_except_ingress_canary.yaml"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newCanaryService(tt.args.sd)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newCanaryService() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newCanaryService() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newCanaryIngress(t *testing.T) {
+	type args struct {
+		sd     *deploymentv1.SingleDeployment
+		weight int32
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *netv1.Ingress
+		wantErr bool
+	}{
+		{
+			name: "Test case create canary ingress with weight",
+			args: args{
+				sd:     makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_canary.yaml"),
+				weight: 10,
+			},
+			want:    makeIngress("ingress_except_ingress_canary.yaml"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newCanaryIngress(tt.args.sd, tt.args.weight)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newCanaryIngress() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newCanaryIngress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newPreviewService(t *testing.T) {
+	type args struct {
+		sd    *deploymentv1.SingleDeployment
+		color string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *corev1.Service
+		wantErr bool
+	}{
+		{
+			name: "Test case create preview service of blue/green",
+			args: args{
+				sd:    makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_bluegreen.yaml"),
+				color: deploymentv1.ColorGreen,
+			},
+			want:    makeService("service_except_ingress_preview.yaml"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newPreviewService(tt.args.sd, tt.args.color)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newPreviewService() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newPreviewService() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newPreviewIngresses(t *testing.T) {
+	type args struct {
+		sd *deploymentv1.SingleDeployment
+	}
+	rewrite := makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_rewrite.yaml")
+	rewrite.Spec.Rollout = &deploymentv1.Rollout{BlueGreen: &deploymentv1.BlueGreenStrategy{PreviewHost: "preview.madongming.com"}}
+	// The path of another host is previewed once
+	rewrite.Spec.Expose.Routes = append(rewrite.Spec.Expose.Routes, deploymentv1.IngressRoute{Host: "cloud.madongming.com", Path: "/", PathType: "Prefix"})
+	tests := []struct {
+		name    string
+		args    args
+		want    []*netv1.Ingress
+		wantErr bool
+	}{
+		{
+			name: "Test case create preview ingress of blue/green",
+			args: args{
+				sd: makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_bluegreen.yaml"),
+			},
+			want:    []*netv1.Ingress{makeIngress("ingress_except_ingress_preview.yaml")},
+			wantErr: false,
+		},
+		{
+			name: "Test case create preview ingresses of routes of rewrite targets",
+			args: args{
+				sd: rewrite,
+			},
+			want: []*netv1.Ingress{
+				makeIngress("ingress_except_ingress_rewrite_preview.yaml"),
+				makeIngress("ingress_except_ingress_rewrite_group_preview.yaml"),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newPreviewIngresses(tt.args.sd)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newPreviewIngresses() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newPreviewIngresses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newControllerRevision(t *testing.T) {
+	type args struct {
+		sd       *deploymentv1.SingleDeployment
+		revision int64
+	}
+	tests := []struct {
+		name     string
+		args     args
+		wantName string
+		wantErr  bool
+	}{
+		{
+			name: "Test case create controller revision of spec",
+			args: args{
+				sd:       makeSingleDeployment("deployment_v1_singledeployment_rc_ingress.yaml"),
+				revision: 1,
+			},
+			wantName: "singledeployment-sample-ingress-63131243",
+			wantErr:  false,
+		},
+		{
+			name: "Test case create controller revision of spec without the revision items",
+			args: args{
+				sd:       makeSingleDeployment("deployment_v1_singledeployment_rc_ingress_rollback.yaml"),
+				revision: 3,
+			},
+			wantName: "singledeployment-sample-ingress-63131243",
+			wantErr:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newControllerRevision(tt.args.sd, tt.args.revision)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newControllerRevision() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got.Name != tt.wantName || got.Revision != tt.args.revision || got.Labels["app"] != tt.args.sd.Name {
+				t.Errorf("newControllerRevision() = %v, want name %v and revision %v", got, tt.wantName, tt.args.revision)
+			}
+			spec := new(deploymentv1.SingleDeploymentSpec)
+			if err := json.Unmarshal(got.Data.Raw, spec); err != nil {
+				t.Errorf("newControllerRevision() data decode error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(spec, revisionSpec(tt.args.sd)) {
+				t.Errorf("newControllerRevision() data = %v, want %v", spec, revisionSpec(tt.args.sd))
+			}
+		})
+	}
+}
+
+func Test_newScheduleStatus(t *testing.T) {
+	scheduleTime := func(value string) *metav1.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			panic(err)
+		}
+		t := metav1.NewTime(parsed.Local())
+		return &t
+	}
+	replicas := func(r int32) *int32 {
+		return &r
+	}
+
+	type args struct {
+		sd  *deploymentv1.SingleDeployment
+		now *metav1.Time
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *deploymentv1.ScheduleStatus
+		wantErr bool
+	}{
+		{
+			name: "Test case schedule after office hours",
+			args: args{
+				sd:  makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_schedule.yaml"),
+				now: scheduleTime("2022-11-16T21:00:00Z"),
+			},
+			want: &deploymentv1.ScheduleStatus{
+				Replicas:     0,
+				NextTime:     scheduleTime("2022-11-17T08:00:00Z"),
+				NextReplicas: replicas(2),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Test case schedule over the weekend",
+			args: args{
+				sd:  makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_schedule.yaml"),
+				now: scheduleTime("2022-11-19T12:00:00Z"),
+			},
+			want: &deploymentv1.ScheduleStatus{
+				Replicas:     0,
+				NextTime:     scheduleTime("2022-11-21T08:00:00Z"),
+				NextReplicas: replicas(2),
+			},
+			wantErr: false,
+		},
+		{
+			name: "Test case schedule in office hours of time zone",
+			args: args{
+				sd:  makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_schedule_tz.yaml"),
+				now: scheduleTime("2022-11-16T01:00:00Z"),
+			},
+			want: &deploymentv1.ScheduleStatus{
+				Replicas:     2,
+				NextTime:     scheduleTime("2022-11-16T12:00:00Z"),
+				NextReplicas: replicas(0),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newScheduleStatus(tt.args.sd, tt.args.now.Time)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newScheduleStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newScheduleStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mainPort(t *testing.T) {
+	dns := deploymentv1.PortSpec{Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP}
+	http := deploymentv1.PortSpec{Name: "http", ContainerPort: 80}
+	api := deploymentv1.PortSpec{Name: "api", ContainerPort: 8080}
+	tests := []struct {
+		name string
+		spec deploymentv1.SingleDeploymentSpec
+		want int32
+	}{
+		{
+			name: "Test case legacy port",
+			spec: deploymentv1.SingleDeploymentSpec{Port: 80},
+			want: 80,
+		},
+		{
+			name: "Test case spec.port is one of the TCP ports",
+			spec: deploymentv1.SingleDeploymentSpec{Port: 8080, Ports: []deploymentv1.PortSpec{http, api}},
+			want: 8080,
+		},
+		{
+			name: "Test case first port is UDP",
+			spec: deploymentv1.SingleDeploymentSpec{Ports: []deploymentv1.PortSpec{dns, http}},
+			want: 80,
+		},
+		{
+			name: "Test case spec.port is UDP",
+			spec: deploymentv1.SingleDeploymentSpec{Port: 53, Ports: []deploymentv1.PortSpec{dns, http}},
+			want: 80,
+		},
+		{
+			name: "Test case no TCP port",
+			spec: deploymentv1.SingleDeploymentSpec{Ports: []deploymentv1.PortSpec{dns}},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mainPort(&deploymentv1.SingleDeployment{Spec: tt.spec}); got != tt.want {
+				t.Errorf("mainPort() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
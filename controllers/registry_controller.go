@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+)
+
+// The waiting reasons of a container whose image can not be pulled
+var imagePullReasons = map[string]bool{
+	"ErrImagePull":     true,
+	"ImagePullBackOff": true,
+	"InvalidImageName": true,
+}
+
+// reconcileRegistryAuth creates/updates the image pull Secret of spec.registryAuth, or deletes it
+// when spec.registryAuth is empty
+func (r *SingleDeploymentReconciler) reconcileRegistryAuth(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) {
+	if err := r.reconcileRegistrySecret(ctx, logger, sd); err != nil {
+		r.setConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeRegistryAuth,
+			sd.Name,
+			err.Error(),
+			deploymentv1.ConditionStatusFailed,
+			deploymentv1.ConditionReasonRegistryAuthUnavailable,
+		)
+		return
+	}
+
+	if sd.Spec.RegistryAuth == nil {
+		r.deleteConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeRegistryAuth,
+		)
+		return
+	}
+	r.setConditions(
+		&sd.Status,
+		deploymentv1.ConditionTypeRegistryAuth,
+		sd.Name,
+		fmt.Sprintf("Secret \"%s\" of registry \"%s\" is created", registrySecretName(sd.Name), sd.Spec.RegistryAuth.Server),
+		deploymentv1.ConditionStatusReady,
+		deploymentv1.ConditionReasonRegistryAuthAvailable,
+	)
+}
+
+func (r *SingleDeploymentReconciler) reconcileRegistrySecret(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	name := registrySecretName(sd.Name)
+	secret := new(corev1.Secret)
+	found := true
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: name}, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			// Its not a "not found err", throw it
+			logger.Error(err, "Get registry secret failed")
+			return fmt.Errorf("Secret \"%s\" get failed: %s", name, err.Error())
+		}
+		found = false
+	}
+	if found && !metav1.IsControlledBy(secret, sd) {
+		return fmt.Errorf("Secret \"%s\" exists and is not controlled by SingleDeployment \"%s\"", name, sd.Name)
+	}
+
+	if sd.Spec.RegistryAuth == nil {
+		// The secret is exist, but registryAuth is removed, delete the secret
+		if found {
+			if err := r.Client.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+				logger.Error(err, "Delete registry secret failed")
+				return fmt.Errorf("Secret \"%s\" delete failed: %s", name, err.Error())
+			}
+		}
+		return nil
+	}
+
+	desired, err := r.generateRegistrySecret(ctx, sd)
+	if err != nil {
+		return err
+	}
+	if !found {
+		// Its a "not found error" that is none a secret, create it.
+		if err := r.Client.Create(ctx, desired); err != nil {
+			logger.Error(err, "Create New registry secret failed")
+			return fmt.Errorf("Secret \"%s\" create failed: %s", name, err.Error())
+		}
+		return nil
+	}
+
+	if reflect.DeepEqual(desired.Data, secret.Data) {
+		return nil
+	}
+	if err := r.Client.Update(ctx, desired); err != nil {
+		logger.Error(err, "Update New registry secret failed")
+		return fmt.Errorf("Secret \"%s\" update failed: %s", name, err.Error())
+	}
+	return nil
+}
+
+// generateRegistrySecret reads the username and password of spec.registryAuth and builds the image pull Secret
+func (r *SingleDeploymentReconciler) generateRegistrySecret(ctx context.Context, sd *deploymentv1.SingleDeployment) (*corev1.Secret, error) {
+	auth := sd.Spec.RegistryAuth
+	source := new(corev1.Secret)
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: auth.SecretName}, source); err != nil {
+		return nil, fmt.Errorf("Secret \"%s\" of registryAuth get failed: %s", auth.SecretName, err.Error())
+	}
+
+	usernameKey, passwordKey := auth.UsernameKey, auth.PasswordKey
+	if usernameKey == "" {
+		usernameKey = corev1.BasicAuthUsernameKey
+	}
+	if passwordKey == "" {
+		passwordKey = corev1.BasicAuthPasswordKey
+	}
+	username, ok := source.Data[usernameKey]
+	if !ok {
+		return nil, fmt.Errorf("Secret \"%s\" of registryAuth has no key \"%s\"", auth.SecretName, usernameKey)
+	}
+	password, ok := source.Data[passwordKey]
+	if !ok {
+		return nil, fmt.Errorf("Secret \"%s\" of registryAuth has no key \"%s\"", auth.SecretName, passwordKey)
+	}
+
+	secret, err := newRegistrySecret(sd, username, password)
+	if err != nil {
+		return nil, err
+	}
+	if err := controllerutil.SetControllerReference(sd, secret, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// imagePullError returns the waiting message of the containers of pods labeled `app: <app>` which can not pull their
+// images, only the pods of instance are checked
+func (r *SingleDeploymentReconciler) imagePullError(ctx context.Context, sd *deploymentv1.SingleDeployment, app string) string {
+	pods := new(corev1.PodList)
+	if err := r.Client.List(ctx, pods,
+		client.InNamespace(sd.Namespace),
+		client.MatchingLabels{"app": app, InstanceLabel: sd.Name},
+	); err != nil {
+		return ""
+	}
+
+	var messages []string
+	for i := range pods.Items {
+		statuses := append(append([]corev1.ContainerStatus{}, pods.Items[i].Status.InitContainerStatuses...), pods.Items[i].Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if status.State.Waiting == nil || !imagePullReasons[status.State.Waiting.Reason] {
+				continue
+			}
+			messages = append(messages, fmt.Sprintf("container \"%s\" of pod \"%s\": %s: %s",
+				status.Name, pods.Items[i].Name, status.State.Waiting.Reason, status.State.Waiting.Message))
+		}
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// findForPod maps a pod to the SingleDeployment named by its instance label
+func (r *SingleDeploymentReconciler) findForPod(obj client.Object) []reconcile.Request {
+	name, ok := obj.GetLabels()[InstanceLabel]
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}},
+	}
+}
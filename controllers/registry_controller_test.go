@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_imagePullError(t *testing.T) {
+	sd := makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport.yaml")
+
+	newPod := func(name string, labels map[string]string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Name = name
+		pod.Namespace = sd.Namespace
+		pod.Labels = labels
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name: sd.Name,
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+				Reason:  "ImagePullBackOff",
+				Message: "Back-off pulling image",
+			}},
+		}}
+		return pod
+	}
+
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want bool
+	}{
+		{
+			name: "Test case pod of instance",
+			pod:  newPod("instance", map[string]string{"app": sd.Name, InstanceLabel: sd.Name}),
+			want: true,
+		},
+		{
+			name: "Test case pod of others with the same app label",
+			pod:  newPod("others", map[string]string{"app": sd.Name}),
+			want: false,
+		},
+		{
+			name: "Test case pod of other instance with the same app label",
+			pod:  newPod("other-instance", map[string]string{"app": sd.Name, InstanceLabel: "other"}),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReconciler(tt.pod)
+			got := r.imagePullError(context.Background(), sd, sd.Name)
+			if (got != "") != tt.want || (tt.want && !strings.Contains(got, tt.pod.Name)) {
+				t.Errorf("imagePullError() = %v, want reported %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_findForPod(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   int
+	}{
+		{
+			name:   "Test case pod labeled by instance",
+			labels: map[string]string{"app": "singledeployment-sample", InstanceLabel: "singledeployment-sample"},
+			want:   1,
+		},
+		{
+			name:   "Test case pod only labeled by app",
+			labels: map[string]string{"app": "singledeployment-sample"},
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{}
+			pod.Namespace = "default"
+			pod.Labels = tt.labels
+			if got := (&SingleDeploymentReconciler{}).findForPod(pod); len(got) != tt.want {
+				t.Errorf("findForPod() = %v, want %d requests", got, tt.want)
+			}
+		})
+	}
+}
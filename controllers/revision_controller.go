@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+)
+
+// RollbackAnnotation restores the spec recorded as the revision of its value, it is removed after the spec is restored
+const RollbackAnnotation = deploymentv1.RollbackAnnotation
+
+//+kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;create;update;patch;delete
+
+// reconcileRollback restores the spec recorded as the revision of spec.rollbackTo or the rollback annotation.
+// It returns true when the instance is updated and the reconcile should stop. A revision not found is
+// reported by reconcileRevision.
+func (r *SingleDeploymentReconciler) reconcileRollback(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) (bool, error) {
+	target, ok := sd.RollbackTarget()
+	if !ok {
+		return false, nil
+	}
+
+	revisions, err := r.listControllerRevisions(ctx, sd)
+	if err != nil {
+		return false, err
+	}
+	var found *appsv1.ControllerRevision
+	for i := range revisions {
+		if strconv.FormatInt(revisions[i].Revision, 10) == target {
+			found = &revisions[i]
+		}
+	}
+	if found == nil {
+		return false, nil
+	}
+
+	spec := new(deploymentv1.SingleDeploymentSpec)
+	if err := json.Unmarshal(found.Data.Raw, spec); err != nil {
+		return false, fmt.Errorf("ControllerRevision \"%s\" decode failed: %s", found.Name, err.Error())
+	}
+	// The history limit is not a part of revisions, keep it
+	spec.RevisionHistoryLimit = sd.Spec.RevisionHistoryLimit
+
+	sdCopy := sd.DeepCopy()
+	sdCopy.Spec = *spec
+	delete(sdCopy.Annotations, RollbackAnnotation)
+	logger.Info("Roll back spec", "revision", found.Revision)
+
+	return true, r.Client.Update(ctx, sdCopy)
+}
+
+// reconcileRevision records the spec of instance as the latest ControllerRevision, and removes the
+// revisions more than spec.revisionHistoryLimit
+func (r *SingleDeploymentReconciler) reconcileRevision(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) {
+	revisions, err := r.listControllerRevisions(ctx, sd)
+	if err != nil {
+		logger.Error(err, "List ControllerRevisions failed")
+		r.setRevisionCondition(sd, fmt.Sprintf("ControllerRevisions list failed: %s", err.Error()), deploymentv1.ConditionStatusFailed)
+		return
+	}
+
+	current, err := r.recordRevision(ctx, logger, sd, revisions)
+	if err != nil {
+		r.setRevisionCondition(sd, err.Error(), deploymentv1.ConditionStatusFailed)
+		return
+	}
+	r.setCurrentRevision(&sd.Status, current.Revision)
+
+	if err := r.pruneRevisions(ctx, logger, sd, revisions, current); err != nil {
+		r.setRevisionCondition(sd, err.Error(), deploymentv1.ConditionStatusFailed)
+		return
+	}
+
+	if target, ok := sd.RollbackTarget(); ok {
+		// reconcileRollback restores the revision found, this one is not recorded
+		r.setRevisionCondition(sd, fmt.Sprintf("Revision %s to roll back to is not found", target), deploymentv1.ConditionStatusFailed)
+		return
+	}
+	r.setRevisionCondition(sd, fmt.Sprintf("Revision %d is applied", current.Revision), deploymentv1.ConditionStatusReady)
+}
+
+// recordRevision returns the revision of spec, a spec recorded before becomes the latest revision again
+func (r *SingleDeploymentReconciler) recordRevision(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, revisions []appsv1.ControllerRevision) (*appsv1.ControllerRevision, error) {
+	cr, err := newControllerRevision(sd, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest int64
+	var existing *appsv1.ControllerRevision
+	for i := range revisions {
+		if revisions[i].Revision > latest {
+			latest = revisions[i].Revision
+		}
+		if revisions[i].Name == cr.Name {
+			existing = &revisions[i]
+		}
+	}
+
+	if existing != nil {
+		if existing.Revision == latest {
+			return existing, nil
+		}
+		// The data of revisions is immutable, only the revision number is moved
+		existing.Revision = latest + 1
+		if err := r.Client.Update(ctx, existing); err != nil {
+			logger.Error(err, "Update ControllerRevision failed")
+			return nil, fmt.Errorf("ControllerRevision \"%s\" update failed: %s", existing.Name, err.Error())
+		}
+		return existing, nil
+	}
+
+	cr.Revision = latest + 1
+	if err := controllerutil.SetControllerReference(sd, cr, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Client.Create(ctx, cr); err != nil {
+		logger.Error(err, "Create ControllerRevision failed")
+		return nil, fmt.Errorf("ControllerRevision \"%s\" create failed: %s", cr.Name, err.Error())
+	}
+	return cr, nil
+}
+
+// pruneRevisions deletes the oldest revisions until spec.revisionHistoryLimit are kept, the current one is always kept
+func (r *SingleDeploymentReconciler) pruneRevisions(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, revisions []appsv1.ControllerRevision, current *appsv1.ControllerRevision) error {
+	limit := int(deploymentv1.DefaultRevisionHistoryLimit)
+	if sd.Spec.RevisionHistoryLimit != nil {
+		limit = int(*sd.Spec.RevisionHistoryLimit)
+	}
+
+	previous := make([]appsv1.ControllerRevision, 0, len(revisions))
+	for i := range revisions {
+		if revisions[i].Name != current.Name {
+			previous = append(previous, revisions[i])
+		}
+	}
+	sort.Slice(previous, func(i, j int) bool {
+		return previous[i].Revision < previous[j].Revision
+	})
+
+	for i := 0; i < len(previous)+1-limit; i++ {
+		if err := r.Client.Delete(ctx, &previous[i]); client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "Delete ControllerRevision failed")
+			return fmt.Errorf("ControllerRevision \"%s\" delete failed: %s", previous[i].Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) listControllerRevisions(ctx context.Context, sd *deploymentv1.SingleDeployment) ([]appsv1.ControllerRevision, error) {
+	list := new(appsv1.ControllerRevisionList)
+	if err := r.Client.List(ctx, list,
+		client.InNamespace(sd.Namespace),
+		client.MatchingLabels{"app": sd.Name},
+	); err != nil {
+		return nil, err
+	}
+
+	revisions := make([]appsv1.ControllerRevision, 0, len(list.Items))
+	for i := range list.Items {
+		if metav1.IsControlledBy(&list.Items[i], sd) {
+			revisions = append(revisions, list.Items[i])
+		}
+	}
+
+	return revisions, nil
+}
+
+func (r *SingleDeploymentReconciler) setRevisionCondition(sd *deploymentv1.SingleDeployment, message, status string) {
+	reason := deploymentv1.ConditionReasonRevisionUnavailable
+	if status == deploymentv1.ConditionStatusReady {
+		reason = deploymentv1.ConditionReasonRevisionAvailable
+	}
+	r.setConditions(
+		&sd.Status,
+		deploymentv1.ConditionTypeRevision,
+		sd.Name,
+		message,
+		status,
+		reason,
+	)
+}
+
+func (r *SingleDeploymentReconciler) setCurrentRevision(
+	sdStatus *deploymentv1.SingleDeploymentStatus,
+	revision int64,
+) {
+	if sdStatus.CurrentRevision == revision {
+		return
+	}
+	sdStatus.CurrentRevision = revision
+	sdStatus.ObservedGeneration++
+}
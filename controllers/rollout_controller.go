@@ -0,0 +1,558 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+)
+
+// RolloutAnnotation asks the running rollout to promote or abort, it is removed after it is handled
+const RolloutAnnotation = "deployment.github.com/rollout"
+
+const (
+	// RolloutPromote completes the current step, e.g. an approval step
+	RolloutPromote = "promote"
+	// RolloutAbort removes the new pod template, the stable Deployment takes all traffic back
+	RolloutAbort = "abort"
+)
+
+// reconcileRollout rolls out a new pod template by spec.rollout. It returns true when the stable Deployment
+// should be updated to the pod template of spec, and the duration after which the rollout needs a reconcile.
+func (r *SingleDeploymentReconciler) reconcileRollout(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, stable *appsv1.Deployment) (bool, time.Duration) {
+	rollout := sd.Spec.Rollout
+	// A suspended instance runs no pods, its pod template is updated directly
+	if rollout == nil || (rollout.Canary == nil && rollout.BlueGreen == nil) || sd.Spec.Suspend {
+		if err := r.cleanupRollout(ctx, logger, sd); err != nil {
+			r.setRolloutCondition(sd, fmt.Sprintf("Rollout cleanup failed: %s", err.Error()), deploymentv1.ConditionStatusFailed)
+			return true, 0
+		}
+		r.setRolloutStatus(&sd.Status, nil)
+		r.deleteConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeRollout,
+		)
+		return true, 0
+	}
+
+	desired, err := r.generateDeployment(sd)
+	if err != nil {
+		// The deployment update reports it
+		return true, 0
+	}
+	revision := templateHash(&desired.Spec.Template)
+	if err := r.Client.Update(ctx, desired, client.DryRunAll); err != nil {
+		r.setRolloutCondition(sd, fmt.Sprintf("Revision %s check failed: %s", revision, err.Error()), deploymentv1.ConditionStatusFailed)
+		return false, 0
+	}
+
+	status := sd.Status.Rollout.DeepCopy()
+	if reflect.DeepEqual(desired.Spec.Template, stable.Spec.Template) {
+		// The stable Deployment runs the pod template of spec, the parallel one is removed after all its pods are updated
+		if status != nil && status.Phase == deploymentv1.RolloutPhasePromoting {
+			if !deploymentRolledOut(stable) {
+				r.setRolloutCondition(sd, fmt.Sprintf("Revision %s is promoting to Deployment \"%s\"", revision, stable.Name), deploymentv1.ConditionStatusUnKnown)
+				return true, 0
+			}
+			if rollout.BlueGreen != nil {
+				// Switch the Service back to the stable Deployment first, the parallel one is removed by the next reconcile
+				status.Phase = deploymentv1.RolloutPhaseCompleted
+				status.ScaleDownAt = nil
+				r.setRolloutStatus(&sd.Status, status)
+				r.setRolloutCondition(sd, fmt.Sprintf("Revision %s is rolled out", revision), deploymentv1.ConditionStatusReady)
+				return true, 0
+			}
+		}
+		if err := r.cleanupRollout(ctx, logger, sd); err != nil {
+			r.setRolloutCondition(sd, fmt.Sprintf("Rollout cleanup failed: %s", err.Error()), deploymentv1.ConditionStatusFailed)
+			return true, 0
+		}
+		completed := &deploymentv1.RolloutStatus{
+			Revision: revision,
+			Phase:    deploymentv1.RolloutPhaseCompleted,
+			Weight:   100,
+		}
+		if rollout.Canary != nil {
+			completed.Step = int32(len(rollout.Canary.Steps))
+		}
+		if status != nil && rollout.BlueGreen != nil {
+			completed.Color = status.Color
+		}
+		r.setRolloutStatus(&sd.Status, completed)
+		r.setRolloutCondition(sd, fmt.Sprintf("Revision %s is rolled out", revision), deploymentv1.ConditionStatusReady)
+		return true, 0
+	}
+
+	if status == nil || status.Revision != revision || status.Phase == deploymentv1.RolloutPhaseCompleted {
+		// A new pod template, start its rollout
+		started := &deploymentv1.RolloutStatus{Revision: revision, Phase: deploymentv1.RolloutPhaseProgressing}
+		if rollout.BlueGreen != nil {
+			started.Color = nextColor(status)
+		}
+		status = started
+	}
+	if status.Phase == deploymentv1.RolloutPhaseAborted {
+		// The running pod template is kept until spec is changed
+		r.setRolloutCondition(sd, fmt.Sprintf("Revision %s is aborted", revision), deploymentv1.ConditionStatusFailed)
+		return false, 0
+	}
+	if status.Phase == deploymentv1.RolloutPhasePromoting {
+		return true, 0
+	}
+
+	action := sd.Annotations[RolloutAnnotation]
+	if action == RolloutAbort {
+		r.abortRollout(ctx, logger, sd, status, "it is aborted by annotation")
+		r.clearRolloutAnnotation(ctx, logger, sd)
+		return false, 0
+	}
+
+	if rollout.Canary != nil {
+		return r.reconcileCanary(ctx, logger, sd, stable, status, action)
+	}
+	return r.reconcileBlueGreen(ctx, logger, sd, stable, status, action)
+}
+
+// reconcileCanary runs the steps of spec.rollout.canary, the weight of traffic is sent to the canary pods
+// after they are available
+func (r *SingleDeploymentReconciler) reconcileCanary(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, stable *appsv1.Deployment, status *deploymentv1.RolloutStatus, action string) (bool, time.Duration) {
+	name := canaryName(sd.Name)
+	if err := r.cleanupRollout(ctx, logger, sd, name); err != nil {
+		r.setRolloutStatus(&sd.Status, status)
+		r.setRolloutCondition(sd, fmt.Sprintf("Rollout cleanup failed: %s", err.Error()), deploymentv1.ConditionStatusFailed)
+		return false, 0
+	}
+
+	canary, err := r.reconcileParallelDeployment(ctx, logger, sd, name, stable)
+	if err != nil {
+		r.setRolloutStatus(&sd.Status, status)
+		r.setRolloutCondition(sd, err.Error(), deploymentv1.ConditionStatusFailed)
+		return false, 0
+	}
+	if failure := r.parallelDeploymentFailure(ctx, sd, canary); failure != "" {
+		r.abortRollout(ctx, logger, sd, status, failure)
+		return false, 0
+	}
+
+	var requeueAfter time.Duration
+	steps := sd.Spec.Rollout.Canary.Steps
+	if deploymentRolledOut(canary) {
+		now := time.Now()
+		for int(status.Step) < len(steps) {
+			step := steps[status.Step]
+			if status.StepStartedAt == nil {
+				startedAt := metav1.NewTime(now)
+				status.StepStartedAt = &startedAt
+			}
+
+			done := false
+			switch {
+			case step.Weight != nil:
+				status.Weight = *step.Weight
+				done = true
+			case step.Pause != nil:
+				if elapsed := now.Sub(status.StepStartedAt.Time); elapsed < step.Pause.Duration {
+					requeueAfter = step.Pause.Duration - elapsed
+				} else {
+					done = true
+				}
+			case step.Approval:
+				status.Phase = deploymentv1.RolloutPhasePaused
+			}
+			// A promote completes one step only
+			if !done && action == RolloutPromote {
+				action = ""
+				r.clearRolloutAnnotation(ctx, logger, sd)
+				done = true
+			}
+			if !done {
+				break
+			}
+			status.Step++
+			status.StepStartedAt = nil
+			status.Phase = deploymentv1.RolloutPhaseProgressing
+			requeueAfter = 0
+		}
+	}
+	if int(status.Step) >= len(steps) {
+		status.Phase = deploymentv1.RolloutPhasePromoting
+	}
+
+	if err := r.reconcileCanaryTraffic(ctx, logger, sd, status.Weight); err != nil {
+		r.setRolloutStatus(&sd.Status, status)
+		r.setRolloutCondition(sd, err.Error(), deploymentv1.ConditionStatusFailed)
+		return false, requeueAfter
+	}
+	r.setRolloutStatus(&sd.Status, status)
+
+	switch {
+	case status.Phase == deploymentv1.RolloutPhasePromoting:
+		r.setRolloutCondition(sd, fmt.Sprintf("Revision %s is promoting to Deployment \"%s\"", status.Revision, stable.Name), deploymentv1.ConditionStatusUnKnown)
+		return true, 0
+	case !deploymentRolledOut(canary):
+		r.setRolloutCondition(sd, fmt.Sprintf("Revision %s is waiting for Deployment \"%s\" to be available", status.Revision, name), deploymentv1.ConditionStatusUnKnown)
+	case status.Phase == deploymentv1.RolloutPhasePaused:
+		r.setRolloutCondition(sd, fmt.Sprintf("Revision %s is waiting for approval at step %d/%d, %d%% of traffic is sent to the canary. Annotate with `%s: %s` to continue",
+			status.Revision, status.Step+1, len(steps), status.Weight, RolloutAnnotation, RolloutPromote), deploymentv1.ConditionStatusUnKnown)
+	default:
+		r.setRolloutCondition(sd, fmt.Sprintf("Revision %s is at step %d/%d, %d%% of traffic is sent to the canary",
+			status.Revision, status.Step+1, len(steps), status.Weight), deploymentv1.ConditionStatusUnKnown)
+	}
+	return false, requeueAfter
+}
+
+// reconcileBlueGreen runs the preview of spec.rollout.blueGreen, and switches the Service to it when it is
+// promoted. The stable Deployment keeps the previous pods until the scale down delay passes, so the switch
+// can be aborted instantly, then it is updated to the pod template of spec.
+func (r *SingleDeploymentReconciler) reconcileBlueGreen(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, stable *appsv1.Deployment, status *deploymentv1.RolloutStatus, action string) (bool, time.Duration) {
+	blueGreen := sd.Spec.Rollout.BlueGreen
+	name := colorName(sd.Name, status.Color)
+	if err := r.cleanupRollout(ctx, logger, sd, name, previewName(sd.Name)); err != nil {
+		r.setRolloutStatus(&sd.Status, status)
+		r.setRolloutCondition(sd, fmt.Sprintf("Rollout cleanup failed: %s", err.Error()), deploymentv1.ConditionStatusFailed)
+		return false, 0
+	}
+
+	preview, err := r.reconcileParallelDeployment(ctx, logger, sd, name, stable)
+	if err == nil {
+		err = r.reconcilePreview(ctx, logger, sd, status.Color)
+	}
+	if err != nil {
+		r.setRolloutStatus(&sd.Status, status)
+		r.setRolloutCondition(sd, err.Error(), deploymentv1.ConditionStatusFailed)
+		return false, 0
+	}
+	if failure := r.parallelDeploymentFailure(ctx, sd, preview); failure != "" {
+		r.abortRollout(ctx, logger, sd, status, failure)
+		return false, 0
+	}
+
+	if status.Phase != deploymentv1.RolloutPhasePromoted {
+		if !deploymentRolledOut(preview) {
+			status.Phase = deploymentv1.RolloutPhaseProgressing
+			r.setRolloutStatus(&sd.Status, status)
+			r.setRolloutCondition(sd, fmt.Sprintf("Revision %s is waiting for Deployment \"%s\" to be available", status.Revision, name), deploymentv1.ConditionStatusUnKnown)
+			return false, 0
+		}
+		if !blueGreen.AutoPromote && action != RolloutPromote {
+			status.Phase = deploymentv1.RolloutPhasePaused
+			r.setRolloutStatus(&sd.Status, status)
+			r.setRolloutCondition(sd, fmt.Sprintf("Revision %s is ready for preview by Service \"%s\". Annotate with `%s: %s` to switch to it",
+				status.Revision, previewName(sd.Name), RolloutAnnotation, RolloutPromote), deploymentv1.ConditionStatusUnKnown)
+			return false, 0
+		}
+		if action == RolloutPromote {
+			r.clearRolloutAnnotation(ctx, logger, sd)
+		}
+
+		// Switch the Service to the preview
+		delay := deploymentv1.DefaultScaleDownDelay
+		if blueGreen.ScaleDownDelay != nil {
+			delay = blueGreen.ScaleDownDelay.Duration
+		}
+		scaleDownAt := metav1.NewTime(time.Now().Add(delay))
+		status.Phase = deploymentv1.RolloutPhasePromoted
+		status.Weight = 100
+		status.ScaleDownAt = &scaleDownAt
+	}
+
+	if remaining := time.Until(status.ScaleDownAt.Time); remaining > 0 {
+		r.setRolloutStatus(&sd.Status, status)
+		r.setRolloutCondition(sd, fmt.Sprintf("Service \"%s\" is switched to Deployment \"%s\", the previous pods are kept until %s. Annotate with `%s: %s` to switch back",
+			sd.Name, name, status.ScaleDownAt.Format(time.RFC3339), RolloutAnnotation, RolloutAbort), deploymentv1.ConditionStatusUnKnown)
+		return false, remaining
+	}
+
+	status.Phase = deploymentv1.RolloutPhasePromoting
+	r.setRolloutStatus(&sd.Status, status)
+	r.setRolloutCondition(sd, fmt.Sprintf("Revision %s is promoting to Deployment \"%s\"", status.Revision, stable.Name), deploymentv1.ConditionStatusUnKnown)
+	return true, 0
+}
+
+// abortRollout removes the parallel Deployment, the stable one keeps all traffic
+func (r *SingleDeploymentReconciler) abortRollout(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, status *deploymentv1.RolloutStatus, reason string) {
+	status.Phase = deploymentv1.RolloutPhaseAborted
+	status.Weight = 0
+	status.StepStartedAt = nil
+	status.ScaleDownAt = nil
+	r.setRolloutStatus(&sd.Status, status)
+
+	if err := r.cleanupRollout(ctx, logger, sd); err != nil {
+		r.setRolloutCondition(sd, fmt.Sprintf("Revision %s is aborted, but rollout cleanup failed: %s", status.Revision, err.Error()), deploymentv1.ConditionStatusFailed)
+		return
+	}
+	r.setRolloutCondition(sd, fmt.Sprintf("Revision %s is aborted: %s", status.Revision, reason), deploymentv1.ConditionStatusFailed)
+}
+
+// clearRolloutAnnotation removes the handled rollout annotation of instance
+func (r *SingleDeploymentReconciler) clearRolloutAnnotation(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) {
+	if _, ok := sd.Annotations[RolloutAnnotation]; !ok {
+		return
+	}
+
+	patched := sd.DeepCopy()
+	delete(patched.Annotations, RolloutAnnotation)
+	if err := r.Client.Patch(ctx, patched, client.MergeFrom(sd)); err != nil {
+		logger.Error(err, "Remove rollout annotation failed")
+		return
+	}
+	// Keep the resource version, so the status of instance can still be updated
+	sd.Annotations = patched.Annotations
+	sd.ResourceVersion = patched.ResourceVersion
+}
+
+func (r *SingleDeploymentReconciler) setRolloutCondition(sd *deploymentv1.SingleDeployment, message, status string) {
+	reason := deploymentv1.ConditionReasonRolloutUnavailable
+	if status == deploymentv1.ConditionStatusReady {
+		reason = deploymentv1.ConditionReasonRolloutAvailable
+	}
+	r.setConditions(
+		&sd.Status,
+		deploymentv1.ConditionTypeRollout,
+		sd.Name,
+		message,
+		status,
+		reason,
+	)
+}
+
+// reconcileParallelDeployment creates/updates the Deployment of name running the pod template of spec beside
+// the stable one, and the Service of canary
+func (r *SingleDeploymentReconciler) reconcileParallelDeployment(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, name string, stable *appsv1.Deployment) (*appsv1.Deployment, error) {
+	var deployment *appsv1.Deployment
+	var err error
+	if name == canaryName(sd.Name) {
+		deployment, err = newCanaryDeployment(sd)
+	} else {
+		deployment, err = newParallelDeployment(sd, name)
+		if err == nil && sd.Spec.Autoscaling != nil {
+			// A full parallel Deployment runs as many pods as the stable one
+			deployment.Spec.Replicas = stable.Spec.Replicas
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.applyRolloutObject(ctx, logger, sd, deployment, new(appsv1.Deployment), func(desired, current client.Object) bool {
+		return reflect.DeepEqual(desired.(*appsv1.Deployment).Spec, current.(*appsv1.Deployment).Spec)
+	}); err != nil {
+		return nil, fmt.Errorf("Deployment \"%s\" apply failed: %s", name, err.Error())
+	}
+
+	if name == canaryName(sd.Name) {
+		service, err := newCanaryService(sd)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.applyRolloutObject(ctx, logger, sd, service, new(corev1.Service), func(desired, current client.Object) bool {
+			return reflect.DeepEqual(desired.(*corev1.Service).Spec, current.(*corev1.Service).Spec)
+		}); err != nil {
+			return nil, fmt.Errorf("Service \"%s\" apply failed: %s", service.Name, err.Error())
+		}
+	}
+
+	current := new(appsv1.Deployment)
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: name}, current); err != nil && !errors.IsNotFound(err) {
+		logger.Error(err, "Get parallel Deployment failed")
+		return nil, fmt.Errorf("Deployment \"%s\" get failed: %s", name, err.Error())
+	}
+	return current, nil
+}
+
+// parallelDeploymentFailure returns why the pods of parallel Deployment can not be available
+func (r *SingleDeploymentReconciler) parallelDeploymentFailure(ctx context.Context, sd *deploymentv1.SingleDeployment, deploy *appsv1.Deployment) string {
+	if deploy.Name == "" {
+		return ""
+	}
+	if message := r.imagePullError(ctx, sd, deploy.Name); message != "" {
+		return fmt.Sprintf("Deployment \"%s\" can not pull the image: %s", deploy.Name, message)
+	}
+	if progressDeadlineExceeded(deploy) {
+		return fmt.Sprintf("Deployment \"%s\" exceeded its progress deadline", deploy.Name)
+	}
+	return ""
+}
+
+// reconcilePreview creates/updates the preview Service of blue/green, and its Ingress in ingress mode
+func (r *SingleDeploymentReconciler) reconcilePreview(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, color string) error {
+	service, err := newPreviewService(sd, color)
+	if err != nil {
+		return err
+	}
+	if err := r.applyRolloutObject(ctx, logger, sd, service, new(corev1.Service), func(desired, current client.Object) bool {
+		return reflect.DeepEqual(desired.(*corev1.Service).Spec, current.(*corev1.Service).Spec)
+	}); err != nil {
+		return fmt.Errorf("Service \"%s\" apply failed: %s", service.Name, err.Error())
+	}
+
+	if strings.ToLower(sd.Spec.Expose.Mode) != ServiceIngress || sd.Spec.Rollout.BlueGreen.PreviewHost == "" {
+		return r.reconcileRouteGroupIngresses(ctx, logger, sd, previewName(sd.Name), nil)
+	}
+	ingresses, err := newPreviewIngresses(sd)
+	if err != nil {
+		return err
+	}
+	if err := r.applyRolloutObject(ctx, logger, sd, ingresses[0], new(netv1.Ingress), func(desired, current client.Object) bool {
+		return reflect.DeepEqual(desired.(*netv1.Ingress).Spec, current.(*netv1.Ingress).Spec) &&
+			reflect.DeepEqual(desired.GetAnnotations(), current.GetAnnotations())
+	}); err != nil {
+		return fmt.Errorf("Ingress \"%s\" apply failed: %s", ingresses[0].Name, err.Error())
+	}
+
+	// The preview of route group Ingresses are labeled by the group of preview
+	return r.reconcileRouteGroupIngresses(ctx, logger, sd, previewName(sd.Name), ingresses[1:])
+}
+
+// reconcileCanaryTraffic creates/updates the canary Ingress sending the weight of traffic to the canary
+func (r *SingleDeploymentReconciler) reconcileCanaryTraffic(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, weight int32) error {
+	ingress, err := newCanaryIngress(sd, weight)
+	if err != nil {
+		return err
+	}
+	if err := r.applyRolloutObject(ctx, logger, sd, ingress, new(netv1.Ingress), func(desired, current client.Object) bool {
+		return reflect.DeepEqual(desired.(*netv1.Ingress).Spec, current.(*netv1.Ingress).Spec) &&
+			reflect.DeepEqual(desired.GetAnnotations(), current.GetAnnotations())
+	}); err != nil {
+		return fmt.Errorf("Ingress \"%s\" apply failed: %s", ingress.Name, err.Error())
+	}
+
+	return nil
+}
+
+// applyRolloutObject creates the object, or updates it when it is not equal to the current one after defaulting
+func (r *SingleDeploymentReconciler) applyRolloutObject(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, desired, current client.Object, equal func(desired, current client.Object) bool) error {
+	if err := controllerutil.SetControllerReference(sd, desired, r.Scheme); err != nil {
+		return err
+	}
+
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(desired), current); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if err := r.Client.Create(ctx, desired); err != nil {
+			logger.Error(err, "Create rollout object failed", "name", desired.GetName())
+			return err
+		}
+		return nil
+	}
+
+	desired.SetResourceVersion(current.GetResourceVersion())
+	if err := r.Client.Update(ctx, desired, client.DryRunAll); err != nil {
+		return err
+	}
+	if equal(desired, current) {
+		return nil
+	}
+	if err := r.Client.Update(ctx, desired); err != nil {
+		logger.Error(err, "Update rollout object failed", "name", desired.GetName())
+		return err
+	}
+
+	return nil
+}
+
+// cleanupRollout deletes the Deployments, Services and Ingresses run beside the stable ones by rollouts,
+// except the ones named by keep
+func (r *SingleDeploymentReconciler) cleanupRollout(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, keep ...string) error {
+	names := []string{
+		canaryName(sd.Name),
+		previewName(sd.Name),
+		colorName(sd.Name, deploymentv1.ColorBlue),
+		colorName(sd.Name, deploymentv1.ColorGreen),
+	}
+	for _, name := range names {
+		if containsString(keep, name) {
+			continue
+		}
+		key := types.NamespacedName{Namespace: sd.Namespace, Name: name}
+		for _, obj := range []client.Object{new(netv1.Ingress), new(corev1.Service), new(appsv1.Deployment)} {
+			if err := r.Client.Get(ctx, key, obj); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return err
+			}
+			if !metav1.IsControlledBy(obj, sd) {
+				continue
+			}
+			if err := r.Client.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+				logger.Error(err, "Delete rollout object failed", "name", name)
+				return err
+			}
+		}
+	}
+	if !containsString(keep, previewName(sd.Name)) {
+		// The preview of route group Ingresses
+		return r.reconcileRouteGroupIngresses(ctx, logger, sd, previewName(sd.Name), nil)
+	}
+
+	return nil
+}
+
+// setRolloutStatus records the progress of rollout
+func (r *SingleDeploymentReconciler) setRolloutStatus(
+	sdStatus *deploymentv1.SingleDeploymentStatus,
+	rollout *deploymentv1.RolloutStatus,
+) {
+	if reflect.DeepEqual(sdStatus.Rollout, rollout) {
+		return
+	}
+	sdStatus.Rollout = rollout
+	sdStatus.ObservedGeneration++
+}
+
+// deploymentRolledOut returns true when all pods of the Deployment run its current pod template and are available
+func deploymentRolledOut(deploy *appsv1.Deployment) bool {
+	if deploy.Generation == 0 || deploy.Status.ObservedGeneration < deploy.Generation {
+		return false
+	}
+	replicas := int32(1)
+	if deploy.Spec.Replicas != nil {
+		replicas = *deploy.Spec.Replicas
+	}
+	return deploy.Status.UpdatedReplicas == replicas &&
+		deploy.Status.AvailableReplicas == replicas &&
+		deploy.Status.Replicas == replicas
+}
+
+// progressDeadlineExceeded returns true when the Deployment failed to make progress in its progress deadline
+func progressDeadlineExceeded(deploy *appsv1.Deployment) bool {
+	for _, cond := range deploy.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing &&
+			cond.Status == corev1.ConditionFalse &&
+			cond.Reason == "ProgressDeadlineExceeded" {
+			return true
+		}
+	}
+	return false
+}
+
+// nextColor returns the color of next parallel Deployment of blue/green, the colors take turns
+func nextColor(status *deploymentv1.RolloutStatus) string {
+	if status != nil && status.Color == deploymentv1.ColorGreen {
+		return deploymentv1.ColorBlue
+	}
+	return deploymentv1.ColorGreen
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+)
+
+// ScheduleOverrideAnnotation pins the replicas of an instance with spec.schedule to its value, spec.schedule
+// applies again after it is removed
+const ScheduleOverrideAnnotation = "deployment.github.com/schedule-override"
+
+// reconcileSchedule records the replicas applied by spec.schedule in status.schedule, the deployment is scaled
+// by them. It returns the duration after which the next entry fires.
+func (r *SingleDeploymentReconciler) reconcileSchedule(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) time.Duration {
+	if len(sd.Spec.Schedule) == 0 {
+		r.setScheduleStatus(&sd.Status, nil)
+		r.deleteConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeSchedule,
+		)
+		return 0
+	}
+
+	if value, ok := sd.Annotations[ScheduleOverrideAnnotation]; ok {
+		replicas, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || replicas < 0 {
+			// Keep the replicas applied, until the annotation is fixed
+			r.setScheduleCondition(sd, fmt.Sprintf("Annotation `%s: %s` is invalid, it must be the replicas", ScheduleOverrideAnnotation, value), deploymentv1.ConditionStatusFailed)
+			return 0
+		}
+		r.setScheduleStatus(&sd.Status, &deploymentv1.ScheduleStatus{Replicas: int32(replicas), Override: true})
+		r.setScheduleCondition(sd, fmt.Sprintf("Replicas are overridden to %d by annotation `%s`", replicas, ScheduleOverrideAnnotation), deploymentv1.ConditionStatusReady)
+		return 0
+	}
+
+	status, err := newScheduleStatus(sd, time.Now())
+	if err != nil {
+		logger.Error(err, "Compute schedule failed")
+		r.setScheduleCondition(sd, fmt.Sprintf("Schedule is invalid: %s", err.Error()), deploymentv1.ConditionStatusFailed)
+		return 0
+	}
+	r.setScheduleStatus(&sd.Status, status)
+
+	if status.NextTime == nil {
+		r.setScheduleCondition(sd, fmt.Sprintf("Replicas are scheduled to %d", status.Replicas), deploymentv1.ConditionStatusReady)
+		return 0
+	}
+	r.setScheduleCondition(sd, fmt.Sprintf("Replicas are scheduled to %d, and to %d at %s",
+		status.Replicas, *status.NextReplicas, status.NextTime.Format(time.RFC3339)), deploymentv1.ConditionStatusReady)
+	requeueAfter := time.Until(status.NextTime.Time)
+	if requeueAfter < time.Second {
+		requeueAfter = time.Second
+	}
+	return requeueAfter
+}
+
+func (r *SingleDeploymentReconciler) setScheduleCondition(sd *deploymentv1.SingleDeployment, message, status string) {
+	reason := deploymentv1.ConditionReasonScheduleUnavailable
+	if status == deploymentv1.ConditionStatusReady {
+		reason = deploymentv1.ConditionReasonScheduleAvailable
+	}
+	r.setConditions(
+		&sd.Status,
+		deploymentv1.ConditionTypeSchedule,
+		sd.Name,
+		message,
+		status,
+		reason,
+	)
+}
+
+func (r *SingleDeploymentReconciler) setScheduleStatus(
+	sdStatus *deploymentv1.SingleDeploymentStatus,
+	schedule *deploymentv1.ScheduleStatus,
+) {
+	if reflect.DeepEqual(sdStatus.Schedule, schedule) {
+		return
+	}
+	sdStatus.Schedule = schedule
+	sdStatus.ObservedGeneration++
+}
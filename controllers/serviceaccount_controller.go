@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+)
+
+// reconcileServiceAccount creates the ServiceAccount, Role and RoleBinding of spec.serviceAccount,
+// and removes the ones which are not needed any more
+func (r *SingleDeploymentReconciler) reconcileServiceAccount(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) {
+	if err := r.reconcileServiceAccountObjects(ctx, logger, sd); err != nil {
+		r.setConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeServiceAccount,
+			sd.Name,
+			err.Error(),
+			deploymentv1.ConditionStatusFailed,
+			deploymentv1.ConditionReasonServiceAccountUnavailable,
+		)
+		return
+	}
+
+	if sd.Spec.ServiceAccount == nil {
+		r.deleteConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeServiceAccount,
+		)
+		return
+	}
+	r.setConditions(
+		&sd.Status,
+		deploymentv1.ConditionTypeServiceAccount,
+		sd.Name,
+		fmt.Sprintf("ServiceAccount \"%s\" is ready", serviceAccountName(sd)),
+		deploymentv1.ConditionStatusReady,
+		deploymentv1.ConditionReasonServiceAccountAvailable,
+	)
+}
+
+func (r *SingleDeploymentReconciler) reconcileServiceAccountObjects(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	create := sd.Spec.ServiceAccount != nil && sd.Spec.ServiceAccount.Create
+	name := ""
+	if sd.Spec.ServiceAccount != nil {
+		name = serviceAccountName(sd)
+	}
+
+	// Delete the created ServiceAccounts which are not used any more, e.g. the name is changed
+	list := new(corev1.ServiceAccountList)
+	if err := r.Client.List(ctx, list,
+		client.InNamespace(sd.Namespace),
+		client.MatchingLabels{"app": sd.Name},
+	); err != nil {
+		logger.Error(err, "List ServiceAccount failed")
+		return fmt.Errorf("ServiceAccounts list failed: %s", err.Error())
+	}
+	for i := range list.Items {
+		if !metav1.IsControlledBy(&list.Items[i], sd) ||
+			(create && list.Items[i].Name == name) {
+			continue
+		}
+		if err := r.Client.Delete(ctx, &list.Items[i]); err != nil && !errors.IsNotFound(err) {
+			logger.Error(err, "Delete ServiceAccount failed")
+			return fmt.Errorf("ServiceAccount \"%s\" delete failed: %s", list.Items[i].Name, err.Error())
+		}
+	}
+
+	if sd.Spec.ServiceAccount != nil {
+		sa := new(corev1.ServiceAccount)
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: name}, sa); err != nil {
+			if !errors.IsNotFound(err) {
+				// Its not a "not found err", throw it
+				logger.Error(err, "Get ServiceAccount failed")
+				return fmt.Errorf("ServiceAccount \"%s\" get failed: %s", name, err.Error())
+			}
+			if !create {
+				return fmt.Errorf("ServiceAccount \"%s\" is not found", name)
+			}
+			// Its a "not found error" that is none a serviceaccount, create it.
+			if err := r.createServiceAccount(ctx, logger, sd); err != nil {
+				return fmt.Errorf("ServiceAccount \"%s\" create failed: %s", name, err.Error())
+			}
+		} else if create && !metav1.IsControlledBy(sa, sd) {
+			return fmt.Errorf("ServiceAccount \"%s\" exists and is not controlled by SingleDeployment \"%s\"", name, sd.Name)
+		}
+	}
+
+	// The Role and RoleBinding grant spec.serviceAccount.rules to the created ServiceAccount
+	withRules := create && len(sd.Spec.ServiceAccount.Rules) != 0
+	role := new(rbacv1.Role)
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: sd.Name}, role); err != nil {
+		if !errors.IsNotFound(err) {
+			logger.Error(err, "Get Role failed")
+			return fmt.Errorf("Role \"%s\" get failed: %s", sd.Name, err.Error())
+		}
+		if withRules {
+			if err := r.createRole(ctx, logger, sd); err != nil {
+				return fmt.Errorf("Role \"%s\" create failed: %s", sd.Name, err.Error())
+			}
+		}
+	} else if !metav1.IsControlledBy(role, sd) {
+		return fmt.Errorf("Role \"%s\" exists and is not controlled by SingleDeployment \"%s\"", sd.Name, sd.Name)
+	} else if withRules {
+		if err := r.updateRole(ctx, logger, sd, role); err != nil {
+			return fmt.Errorf("Role \"%s\" update failed: %s", sd.Name, err.Error())
+		}
+	} else if err := r.Client.Delete(ctx, role); err != nil && !errors.IsNotFound(err) {
+		logger.Error(err, "Delete Role failed")
+		return fmt.Errorf("Role \"%s\" delete failed: %s", sd.Name, err.Error())
+	}
+
+	binding := new(rbacv1.RoleBinding)
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: sd.Name}, binding); err != nil {
+		if !errors.IsNotFound(err) {
+			logger.Error(err, "Get RoleBinding failed")
+			return fmt.Errorf("RoleBinding \"%s\" get failed: %s", sd.Name, err.Error())
+		}
+		if withRules {
+			if err := r.createRoleBinding(ctx, logger, sd); err != nil {
+				return fmt.Errorf("RoleBinding \"%s\" create failed: %s", sd.Name, err.Error())
+			}
+		}
+	} else if !metav1.IsControlledBy(binding, sd) {
+		return fmt.Errorf("RoleBinding \"%s\" exists and is not controlled by SingleDeployment \"%s\"", sd.Name, sd.Name)
+	} else if withRules {
+		if err := r.updateRoleBinding(ctx, logger, sd, binding); err != nil {
+			return fmt.Errorf("RoleBinding \"%s\" update failed: %s", sd.Name, err.Error())
+		}
+	} else if err := r.Client.Delete(ctx, binding); err != nil && !errors.IsNotFound(err) {
+		logger.Error(err, "Delete RoleBinding failed")
+		return fmt.Errorf("RoleBinding \"%s\" delete failed: %s", sd.Name, err.Error())
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) createServiceAccount(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	sa := newServiceAccount(sd)
+	if err := controllerutil.SetControllerReference(sd, sa, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Client.Create(ctx, sa); err != nil {
+		logger.Error(err, "Create New ServiceAccount failed")
+		return err
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) generateRole(sd *deploymentv1.SingleDeployment) (*rbacv1.Role, error) {
+	role := newRole(sd)
+	if err := controllerutil.SetControllerReference(sd, role, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+func (r *SingleDeploymentReconciler) createRole(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	role, err := r.generateRole(sd)
+	if err != nil {
+		return err
+	}
+	if err := r.Client.Create(ctx, role); err != nil {
+		logger.Error(err, "Create New Role failed")
+		return err
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) updateRole(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, ro *rbacv1.Role) error {
+	role, err := r.generateRole(sd)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Client.Update(ctx, role, client.DryRunAll); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(role.Rules, ro.Rules) {
+		return nil
+	}
+
+	if err := r.Client.Update(ctx, role); err != nil {
+		logger.Error(err, "Update New Role failed")
+		return err
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) generateRoleBinding(sd *deploymentv1.SingleDeployment) (*rbacv1.RoleBinding, error) {
+	binding := newRoleBinding(sd)
+	if err := controllerutil.SetControllerReference(sd, binding, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	return binding, nil
+}
+
+func (r *SingleDeploymentReconciler) createRoleBinding(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	binding, err := r.generateRoleBinding(sd)
+	if err != nil {
+		return err
+	}
+	if err := r.Client.Create(ctx, binding); err != nil {
+		logger.Error(err, "Create New RoleBinding failed")
+		return err
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) updateRoleBinding(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, rb *rbacv1.RoleBinding) error {
+	binding, err := r.generateRoleBinding(sd)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Client.Update(ctx, binding, client.DryRunAll); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(binding.Subjects, rb.Subjects) {
+		return nil
+	}
+
+	if err := r.Client.Update(ctx, binding); err != nil {
+		logger.Error(err, "Update New RoleBinding failed")
+		return err
+	}
+
+	return nil
+}
@@ -25,23 +25,46 @@ import (
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	netv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
 )
 
+// The index fields of SingleDeployment for the names in spec.envFrom
+const (
+	EnvFromConfigMapField   = ".spec.envFrom.configMapRef.name"
+	EnvFromSecretField      = ".spec.envFrom.secretRef.name"
+	RegistryAuthSecretField = ".spec.registryAuth.secretName"
+	TLSSecretField          = ".spec.expose.tls.secretName"
+)
+
 // SingleDeploymentReconciler reconciles a SingleDeployment object
 type SingleDeploymentReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// gatewayInstalled is set by SetupWithManager when the cluster serves HTTPRoute
+	gatewayInstalled bool
+	// certManagerInstalled is set by SetupWithManager when the cluster serves Certificate
+	certManagerInstalled bool
 }
 
 //+kubebuilder:rbac:groups=deployment.github.com,resources=singledeployments,verbs=get;list;watch;create;update;patch;delete
@@ -50,6 +73,17 @@ type SingleDeploymentReconciler struct {
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="networking.k8s.io",resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=escalate;bind
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+//+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -69,13 +103,93 @@ func (r *SingleDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// The instance is deleting, release the retained storage
+	if !sd.DeletionTimestamp.IsZero() {
+		if err := r.finalizeStorage(ctx, logger, sd); err != nil {
+			logger.Error(err, "Finalize storage failed")
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if updated, err := r.reconcileStorageFinalizer(ctx, sd); err != nil {
+		logger.Error(err, "Update storage finalizer failed")
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	} else if updated {
+		// The update triggers a new reconcile
+		return ctrl.Result{}, nil
+	}
+
+	// Restore an older spec by spec.rollbackTo or the rollback annotation
+	if restored, err := r.reconcileRollback(ctx, logger, sd); err != nil {
+		logger.Error(err, "Roll back spec failed")
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	} else if restored {
+		// The update triggers a new reconcile
+		return ctrl.Result{}, nil
+	}
+
 	// Deep-copy single deployment otherwise we are mutating our cache
 	sdCopy := sd.DeepCopy()
 
+	// Record the spec as a ControllerRevision
+	///////////////////////////////////////////////////////////////
+	r.reconcileRevision(ctx, logger, sdCopy)
+	///////////////////////////////////////////////////////////////
+
+	// Build image from source if spec.image is empty
+	///////////////////////////////////////////////////////////////
+	imageReady := r.reconcileBuild(ctx, logger, sdCopy)
+	///////////////////////////////////////////////////////////////
+
+	// Create/update the PersistentVolumeClaims of spec.storage
+	///////////////////////////////////////////////////////////////
+	r.reconcileStorage(ctx, logger, sdCopy)
+	///////////////////////////////////////////////////////////////
+
+	// Create/update the ServiceAccount, Role and RoleBinding of spec.serviceAccount
+	///////////////////////////////////////////////////////////////
+	r.reconcileServiceAccount(ctx, logger, sdCopy)
+	///////////////////////////////////////////////////////////////
+
+	// Create/update the image pull Secret of spec.registryAuth
+	///////////////////////////////////////////////////////////////
+	r.reconcileRegistryAuth(ctx, logger, sdCopy)
+	///////////////////////////////////////////////////////////////
+
+	// Create/update the ConfigMap of spec.configFiles and check spec.envFrom
+	///////////////////////////////////////////////////////////////
+	configReady := r.reconcileConfig(ctx, logger, sdCopy)
+	///////////////////////////////////////////////////////////////
+
+	// Apply the replicas of spec.schedule
+	///////////////////////////////////////////////////////////////
+	requeueAfter := r.reconcileSchedule(ctx, logger, sdCopy)
+	///////////////////////////////////////////////////////////////
+
 	// Watch and create/update deployment
 	///////////////////////////////////////////////////////////////
 	deployment := &appsv1.Deployment{}
-	if err := r.Client.Get(ctx, req.NamespacedName, deployment); err != nil {
+	if !imageReady {
+		// Do not create or update deployment until the image is built
+		r.setConditions(
+			&sdCopy.Status,
+			deploymentv1.ConditionTypeDeployment,
+			sdCopy.Name,
+			fmt.Sprintf("Deployment \"%s\" is waiting for the image build", sdCopy.Name),
+			deploymentv1.ConditionStatusUnKnown,
+			deploymentv1.ConditionReasonDeploymentUnavailable,
+		)
+	} else if !configReady {
+		// Do not create or update deployment until the references of envFrom exist
+		r.setConditions(
+			&sdCopy.Status,
+			deploymentv1.ConditionTypeDeployment,
+			sdCopy.Name,
+			fmt.Sprintf("Deployment \"%s\" is waiting for the envFrom references", sdCopy.Name),
+			deploymentv1.ConditionStatusUnKnown,
+			deploymentv1.ConditionReasonDeploymentUnavailable,
+		)
+	} else if err := r.Client.Get(ctx, req.NamespacedName, deployment); err != nil {
 		if errors.IsNotFound(err) {
 			// Its a "not found error" that is none a deployment, create it.
 
@@ -114,9 +228,15 @@ func (r *SingleDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		}
 	} else {
 		// Exist the deployment, update it
+		// Record the replicas before spec.suspend scales the deployment to 0
+		r.reconcileSuspend(sdCopy, deployment)
+
+		// A new pod template is rolled out by spec.rollout before it is set to the deployment
+		promoted, rolloutRequeueAfter := r.reconcileRollout(ctx, logger, sdCopy, deployment)
+		requeueAfter = earliestRequeue(requeueAfter, rolloutRequeueAfter)
 
 		// Update deployment, include status
-		if err := r.updateDeployment(ctx, logger, sdCopy, deployment); err != nil {
+		if err := r.updateDeployment(ctx, logger, sdCopy, deployment, !promoted); err != nil {
 			// update failed
 			logger.Error(err, "Update Deployment failed")
 			r.setConditions(
@@ -128,7 +248,7 @@ func (r *SingleDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 				deploymentv1.ConditionReasonDeploymentUnavailable,
 			)
 			// Sync deployment status to singledeployment
-		} else if deployment.Status.AvailableReplicas == sdCopy.Spec.Replicas {
+		} else if deployment.Status.AvailableReplicas == r.desiredReplicas(ctx, sdCopy, deployment) {
 			r.setConditions(
 				&sdCopy.Status,
 				deploymentv1.ConditionTypeDeployment,
@@ -137,6 +257,16 @@ func (r *SingleDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 				deploymentv1.ConditionStatusReady,
 				deploymentv1.ConditionReasonDeploymentAvailable,
 			)
+		} else if message := r.imagePullError(ctx, sdCopy, sdCopy.Name); message != "" {
+			// The pods will not be available until the image can be pulled
+			r.setConditions(
+				&sdCopy.Status,
+				deploymentv1.ConditionTypeDeployment,
+				sdCopy.Name,
+				fmt.Sprintf("Deployment \"%s\" can not pull the image: %s", sdCopy.Name, message),
+				deploymentv1.ConditionStatusFailed,
+				deploymentv1.ConditionReasonDeploymentImagePullFailed,
+			)
 		} else {
 			r.setConditions(
 				&sdCopy.Status,
@@ -148,6 +278,15 @@ func (r *SingleDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 			)
 		}
 	}
+	// Report the QoS class the pods get from spec.resources
+	if desired, err := newDeployment(sdCopy); err == nil {
+		r.setQOSClass(&sdCopy.Status, getQOSClass(&desired.Spec.Template.Spec))
+	}
+	///////////////////////////////////////////////////////////////
+
+	// Create/update the HorizontalPodAutoscaler of spec.autoscaling
+	///////////////////////////////////////////////////////////////
+	r.reconcileAutoscaling(ctx, logger, sdCopy)
 	///////////////////////////////////////////////////////////////
 
 	// Ingress mode or NodePort mode
@@ -159,7 +298,13 @@ func (r *SingleDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		if errors.IsNotFound(err) {
 			// Its a "not found error" that is none a service, create it.
 			// Create service
-			if errCreate := r.createService(ctx, logger, sdCopy); errCreate != nil {
+			if strings.ToLower(sdCopy.Spec.Expose.Mode) == ServiceNone {
+				// The instance is not exposed
+				r.deleteConditions(
+					&sdCopy.Status,
+					deploymentv1.ConditionTypeService,
+				)
+			} else if errCreate := r.createService(ctx, logger, sdCopy); errCreate != nil {
 				// create failed
 				logger.Error(errCreate, "Create Service failed")
 				r.setConditions(
@@ -170,6 +315,9 @@ func (r *SingleDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 					deploymentv1.ConditionStatusFailed,
 					deploymentv1.ConditionReasonServiceUnavailable,
 				)
+			} else if strings.ToLower(sdCopy.Spec.Expose.Mode) == ServiceLoadBalancer {
+				// The load balancer is not provisioned yet
+				r.setServiceAddressCondition(sdCopy)
 			} else {
 				// if Service create / update call is success,it is alway created successful
 				r.setConditions(
@@ -196,8 +344,25 @@ func (r *SingleDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	} else {
 		// The service is exist update the service
 
-		// Update service
-		if err := r.updateService(ctx, logger, sdCopy, service); err != nil {
+		if strings.ToLower(sdCopy.Spec.Expose.Mode) == ServiceNone {
+			// The service is exist, but mode is set none, delete the service
+			if err := r.deleteService(ctx, logger, service); err != nil {
+				r.setConditions(
+					&sdCopy.Status,
+					deploymentv1.ConditionTypeService,
+					sdCopy.Name,
+					fmt.Sprintf("Service \"%s\" is delete failed: %s", sdCopy.Name, err.Error()),
+					deploymentv1.ConditionStatusFailed,
+					deploymentv1.ConditionReasonServiceUnavailable,
+				)
+			} else {
+				r.reconcileServiceAddress(sdCopy, service)
+				r.deleteConditions(
+					&sdCopy.Status,
+					deploymentv1.ConditionTypeService,
+				)
+			}
+		} else if err := r.updateService(ctx, logger, sdCopy, service); err != nil {
 			// update failed
 			logger.Error(err, "update Service failed")
 			r.setConditions(
@@ -208,6 +373,8 @@ func (r *SingleDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 				deploymentv1.ConditionStatusFailed,
 				deploymentv1.ConditionReasonServiceUnavailable,
 			)
+		} else if !r.reconcileServiceAddress(sdCopy, service) {
+			r.setServiceAddressCondition(sdCopy)
 		} else {
 			// if Service create / update call is success,it is alway created successful,
 			r.setConditions(
@@ -240,7 +407,7 @@ func (r *SingleDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 						deploymentv1.ConditionReasonServiceUnavailable,
 					)
 				}
-			} else if strings.ToLower(sdCopy.Spec.Expose.Mode) == ServiceNodePort {
+			} else {
 				r.deleteConditions(
 					&sdCopy.Status,
 					deploymentv1.ConditionTypeIngress,
@@ -286,8 +453,8 @@ func (r *SingleDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 					deploymentv1.ConditionReasonIngressAvailable,
 				)
 			}
-		} else if strings.ToLower(sd.Spec.Expose.Mode) == ServiceNodePort {
-			// The ingress is exist, but mode is set nodeport, delete the ingress
+		} else {
+			// The ingress is exist, but mode is not ingress, delete the ingress
 			// Delete ingress
 			if err := r.deleteIngress(ctx, logger, ingress); err != nil {
 				// delete failed
@@ -308,11 +475,127 @@ func (r *SingleDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 			}
 		}
 	}
+	// The routes of the other rewrite targets are served by their own Ingresses, they are deleted out of ingress mode
+	if err := r.reconcileRouteGroupIngresses(ctx, logger, sdCopy, sdCopy.Name, newRouteGroupIngresses(sdCopy)); err != nil {
+		r.setConditions(
+			&sdCopy.Status,
+			deploymentv1.ConditionTypeIngress,
+			sdCopy.Name,
+			err.Error(),
+			deploymentv1.ConditionStatusFailed,
+			deploymentv1.ConditionReasonServiceUnavailable,
+		)
+	}
+	///////////////////////////////////////////////////////////////
+
+	// Watch and create/update/delete Certificate, and check the certificate of Ingress
+	///////////////////////////////////////////////////////////////
+	requeueAfter = earliestRequeue(requeueAfter, r.reconcileCertificate(ctx, logger, sdCopy))
+	///////////////////////////////////////////////////////////////
+
+	// Gateway mode
+	// Watch and create/update/delete HTTPRoute
+	///////////////////////////////////////////////////////////////
+	r.reconcileGateway(ctx, logger, sdCopy)
+	///////////////////////////////////////////////////////////////
+
+	// Watch and create/update/delete PodDisruptionBudget
+	///////////////////////////////////////////////////////////////
+	pdb := new(policyv1.PodDisruptionBudget)
+	if err := r.Client.Get(ctx, req.NamespacedName, pdb); err != nil {
+		if errors.IsNotFound(err) {
+			// Its a "not found error" that is not exsit a pdb, create it.
+			if needDisruptionBudget(sdCopy) {
+				// The instance has more than 1 replica, Create pdb
+				if errCreate := r.createPodDisruptionBudget(ctx, logger, sdCopy); errCreate != nil {
+					// create failed
+					logger.Error(errCreate, "Create PodDisruptionBudget failed")
+					r.setConditions(
+						&sdCopy.Status,
+						deploymentv1.ConditionTypeDisruptionBudget,
+						sdCopy.Name,
+						fmt.Sprintf("PodDisruptionBudget \"%s\" is create failed: %s", sdCopy.Name, errCreate.Error()),
+						deploymentv1.ConditionStatusFailed,
+						deploymentv1.ConditionReasonDisruptionBudgetUnavailable,
+					)
+				} else {
+					r.setConditions(
+						&sdCopy.Status,
+						deploymentv1.ConditionTypeDisruptionBudget,
+						sdCopy.Name,
+						fmt.Sprintf("PodDisruptionBudget \"%s\" is created", sdCopy.Name),
+						deploymentv1.ConditionStatusReady,
+						deploymentv1.ConditionReasonDisruptionBudgetAvailable,
+					)
+				}
+			} else {
+				r.deleteConditions(
+					&sdCopy.Status,
+					deploymentv1.ConditionTypeDisruptionBudget,
+				)
+			}
+		} else {
+			// Its not a "not found err", throw it
+			logger.Error(err, "Get PodDisruptionBudget failed")
+			r.setConditions(
+				&sdCopy.Status,
+				deploymentv1.ConditionTypeDisruptionBudget,
+				sdCopy.Name,
+				fmt.Sprintf("PodDisruptionBudget \"%s\" is get failed: %s", sdCopy.Name, err.Error()),
+				deploymentv1.ConditionStatusFailed,
+				deploymentv1.ConditionReasonDisruptionBudgetUnavailable,
+			)
+		}
+	} else {
+		if needDisruptionBudget(sdCopy) {
+			// The pdb is exist and the instance has more than 1 replica, update the pdb
+			if err := r.updatePodDisruptionBudget(ctx, logger, sdCopy, pdb); err != nil {
+				// update failed
+				logger.Error(err, "Update PodDisruptionBudget failed")
+				r.setConditions(
+					&sdCopy.Status,
+					deploymentv1.ConditionTypeDisruptionBudget,
+					sdCopy.Name,
+					fmt.Sprintf("PodDisruptionBudget \"%s\" is update failed: %s", sdCopy.Name, err.Error()),
+					deploymentv1.ConditionStatusFailed,
+					deploymentv1.ConditionReasonDisruptionBudgetUnavailable,
+				)
+			} else {
+				r.setConditions(
+					&sdCopy.Status,
+					deploymentv1.ConditionTypeDisruptionBudget,
+					sdCopy.Name,
+					fmt.Sprintf("PodDisruptionBudget \"%s\" is created", sdCopy.Name),
+					deploymentv1.ConditionStatusReady,
+					deploymentv1.ConditionReasonDisruptionBudgetAvailable,
+				)
+			}
+		} else {
+			// The pdb is exist, but the instance is scaled to 1 replica, delete the pdb
+			if err := r.deletePodDisruptionBudget(ctx, logger, pdb); err != nil {
+				// delete failed
+				logger.Error(err, "Delete PodDisruptionBudget failed")
+				r.setConditions(
+					&sdCopy.Status,
+					deploymentv1.ConditionTypeDisruptionBudget,
+					sdCopy.Name,
+					fmt.Sprintf("PodDisruptionBudget \"%s\" is delete failed: %s", sdCopy.Name, err.Error()),
+					deploymentv1.ConditionStatusFailed,
+					deploymentv1.ConditionReasonDisruptionBudgetUnavailable,
+				)
+			} else {
+				r.deleteConditions(
+					&sdCopy.Status,
+					deploymentv1.ConditionTypeDisruptionBudget,
+				)
+			}
+		}
+	}
 	///////////////////////////////////////////////////////////////
 
 	// All work is done
 	// Judging `status` according to conditions
-	r.processStatus(&sdCopy.Status)
+	r.processStatus(&sdCopy.Status, sdCopy.Spec.Suspend)
 
 	if sd.Status.ObservedGeneration != sdCopy.Status.ObservedGeneration {
 		// Some status is changed, update
@@ -322,17 +605,78 @@ func (r *SingleDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		}
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// NewCache builds the cache of the Manager, only the pods labeled by InstanceLabel are cached instead of all the
+// pods in the cluster
+func NewCache(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+	instance, err := labels.NewRequirement(InstanceLabel, selection.Exists, nil)
+	if err != nil {
+		return nil, err
+	}
+	return cache.BuilderWithOptions(cache.Options{
+		SelectorsByObject: cache.SelectorsByObject{
+			&corev1.Pod{}: {Label: labels.NewSelector().Add(*instance)},
+		},
+	})(config, opts)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *SingleDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	// Index the references of spec.envFrom, they are not owned and are watched by the index
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &deploymentv1.SingleDeployment{}, EnvFromConfigMapField, envFromIndexer(false)); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &deploymentv1.SingleDeployment{}, EnvFromSecretField, envFromIndexer(true)); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &deploymentv1.SingleDeployment{}, RegistryAuthSecretField, registryAuthIndexer); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &deploymentv1.SingleDeployment{}, TLSSecretField, tlsSecretIndexer); err != nil {
+		return err
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&deploymentv1.SingleDeployment{}).
 		Owns(&appsv1.Deployment{}).
 		Owns(&netv1.Ingress{}).
 		Owns(&corev1.Service{}).
-		Complete(r)
+		Owns(&batchv1.Job{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Secret{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&rbacv1.Role{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Owns(&appsv1.ControllerRevision{}).
+		Watches(
+			&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.findReferencing(EnvFromConfigMapField)),
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.findReferencing(EnvFromSecretField, RegistryAuthSecretField, TLSSecretField)),
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Pod{}},
+			handler.EnqueueRequestsFromMapFunc(r.findForPod),
+		)
+	// Gateway API is optional, HTTPRoute is only watched when its CRD is installed
+	r.gatewayInstalled = gatewayInstalled(mgr.GetRESTMapper())
+	if r.gatewayInstalled {
+		b = b.Owns(newBaseHTTPRoute("", ""))
+	}
+	// cert-manager is optional too
+	r.certManagerInstalled = certManagerInstalled(mgr.GetRESTMapper())
+	if r.certManagerInstalled {
+		b = b.Owns(newBaseCertificate("", ""))
+	}
+
+	return b.Complete(r)
 }
 
 // private methods
@@ -363,11 +707,20 @@ func (r *SingleDeploymentReconciler) createDeployment(ctx context.Context, logge
 	return nil
 }
 
-func (r *SingleDeploymentReconciler) updateDeployment(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, deploy *appsv1.Deployment) error {
+// updateDeployment updates the deployment to spec. The running pod template is kept when keepTemplate is true,
+// e.g. a new one is being rolled out
+func (r *SingleDeploymentReconciler) updateDeployment(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, deploy *appsv1.Deployment, keepTemplate bool) error {
 	deployment, err := r.generateDeployment(sd)
 	if err != nil {
 		return err
 	}
+	if sd.Spec.Autoscaling != nil && !sd.Spec.Suspend && !suspended(deploy) {
+		// The replicas are managed by the HorizontalPodAutoscaler, keep them
+		deployment.Spec.Replicas = deploy.Spec.Replicas
+	}
+	if keepTemplate {
+		deployment.Spec.Template = *deploy.Spec.Template.DeepCopy()
+	}
 
 	if err := r.Client.Update(ctx, deployment, client.DryRunAll); err != nil {
 		return err
@@ -416,6 +769,7 @@ func (r *SingleDeploymentReconciler) createService(ctx context.Context, logger l
 	if err != nil {
 		return err
 	}
+	service.Annotations = mergeAnnotations(nil, service.Annotations)
 	if err := r.Client.Create(ctx, service); err != nil {
 		logger.Error(err, "Create New service failed")
 		return err
@@ -429,12 +783,15 @@ func (r *SingleDeploymentReconciler) updateService(ctx context.Context, logger l
 	if err != nil {
 		return err
 	}
+	// The annotations of others, e.g. the address pool of load balancer, are kept
+	service.Annotations = mergeAnnotations(svc.Annotations, service.Annotations)
 
 	if err := r.Client.Update(ctx, service, client.DryRunAll); err != nil {
 		return err
 	}
 
-	if reflect.DeepEqual(service.Spec, svc.Spec) {
+	if reflect.DeepEqual(service.Spec, svc.Spec) &&
+		reflect.DeepEqual(service.Annotations, svc.Annotations) {
 		return nil
 	}
 
@@ -451,6 +808,7 @@ func (r *SingleDeploymentReconciler) createIngress(ctx context.Context, logger l
 	if err != nil {
 		return err
 	}
+	ingress.Annotations = mergeAnnotations(nil, ingress.Annotations)
 	if err := r.Client.Create(ctx, ingress); err != nil {
 		logger.Error(err, "Create New Ingress failed")
 		return err
@@ -464,12 +822,15 @@ func (r *SingleDeploymentReconciler) updateIngress(ctx context.Context, logger l
 	if err != nil {
 		return err
 	}
+	// The annotations of others are kept like the ones of Service
+	ingress.Annotations = mergeAnnotations(ig.Annotations, ingress.Annotations)
 
 	if err := r.Client.Update(ctx, ingress, client.DryRunAll); err != nil {
 		return err
 	}
 
-	if reflect.DeepEqual(ingress.Spec, ig.Spec) {
+	if reflect.DeepEqual(ingress.Spec, ig.Spec) &&
+		reflect.DeepEqual(ingress.Annotations, ig.Annotations) {
 		return nil
 	}
 
@@ -483,7 +844,7 @@ func (r *SingleDeploymentReconciler) updateIngress(ctx context.Context, logger l
 
 func (r *SingleDeploymentReconciler) deleteService(ctx context.Context, logger logr.Logger, service *corev1.Service) error {
 	if err := r.Client.Delete(ctx, service); err != nil {
-		logger.Error(err, "Update New Ingress failed")
+		logger.Error(err, "Delete Service failed")
 		return err
 	}
 	return nil
@@ -497,6 +858,69 @@ func (r *SingleDeploymentReconciler) deleteIngress(ctx context.Context, logger l
 	return nil
 }
 
+func (r *SingleDeploymentReconciler) generatePodDisruptionBudget(sd *deploymentv1.SingleDeployment) (*policyv1.PodDisruptionBudget, error) {
+	pdb := newPodDisruptionBudget(sd)
+	err := controllerutil.SetControllerReference(sd, pdb, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	return pdb, nil
+}
+
+func (r *SingleDeploymentReconciler) createPodDisruptionBudget(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	pdb, err := r.generatePodDisruptionBudget(sd)
+	if err != nil {
+		return err
+	}
+	if err := r.Client.Create(ctx, pdb); err != nil {
+		logger.Error(err, "Create New PodDisruptionBudget failed")
+		return err
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) updatePodDisruptionBudget(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, p *policyv1.PodDisruptionBudget) error {
+	pdb, err := r.generatePodDisruptionBudget(sd)
+	if err != nil {
+		return err
+	}
+	// PodDisruptionBudget is updated with optimistic concurrency
+	pdb.ResourceVersion = p.ResourceVersion
+
+	if err := r.Client.Update(ctx, pdb, client.DryRunAll); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(pdb.Spec, p.Spec) {
+		return nil
+	}
+
+	if err := r.Client.Update(ctx, pdb); err != nil {
+		logger.Error(err, "Update New PodDisruptionBudget failed")
+		return err
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) deletePodDisruptionBudget(ctx context.Context, logger logr.Logger, pdb *policyv1.PodDisruptionBudget) error {
+	if err := r.Client.Delete(ctx, pdb); err != nil {
+		logger.Error(err, "Delete PodDisruptionBudget failed")
+		return err
+	}
+	return nil
+}
+
+// earliestRequeue returns the shorter one of durations after which a reconcile is needed, 0 means none is needed
+func earliestRequeue(a, b time.Duration) time.Duration {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
 func (r *SingleDeploymentReconciler) setStatus(
 	sdStatus *deploymentv1.SingleDeploymentStatus,
 	phase,
@@ -522,6 +946,39 @@ func (r *SingleDeploymentReconciler) setStatus(
 	}
 }
 
+func (r *SingleDeploymentReconciler) setBuildStatus(
+	sdStatus *deploymentv1.SingleDeploymentStatus,
+	build *deploymentv1.BuildStatus,
+) {
+	if reflect.DeepEqual(sdStatus.Build, build) {
+		return
+	}
+	sdStatus.Build = build
+	sdStatus.ObservedGeneration++
+}
+
+func (r *SingleDeploymentReconciler) setQOSClass(
+	sdStatus *deploymentv1.SingleDeploymentStatus,
+	qosClass corev1.PodQOSClass,
+) {
+	if sdStatus.QOSClass == qosClass {
+		return
+	}
+	sdStatus.QOSClass = qosClass
+	sdStatus.ObservedGeneration++
+}
+
+func (r *SingleDeploymentReconciler) setEnvFromHash(
+	sdStatus *deploymentv1.SingleDeploymentStatus,
+	hash string,
+) {
+	if sdStatus.EnvFromHash == hash {
+		return
+	}
+	sdStatus.EnvFromHash = hash
+	sdStatus.ObservedGeneration++
+}
+
 func (r *SingleDeploymentReconciler) setConditions(
 	sds *deploymentv1.SingleDeploymentStatus,
 	condType string,
@@ -581,7 +1038,7 @@ func (r *SingleDeploymentReconciler) deleteConditions(
 	}
 }
 
-func (r *SingleDeploymentReconciler) processStatus(sds *deploymentv1.SingleDeploymentStatus) {
+func (r *SingleDeploymentReconciler) processStatus(sds *deploymentv1.SingleDeploymentStatus, suspend bool) {
 	isDone := true
 	isFailed := false
 	for i := range sds.Conditions {
@@ -599,6 +1056,13 @@ func (r *SingleDeploymentReconciler) processStatus(sds *deploymentv1.SingleDeplo
 			fmt.Sprint("SingleDeployment create/update is failed"),
 			deploymentv1.StatusReasonDependsUnavailable,
 		)
+	} else if suspend {
+		r.setStatus(
+			sds,
+			deploymentv1.StatusPhaseSuspended,
+			fmt.Sprint("SingleDeployment is suspended"),
+			deploymentv1.StatusReasonDependsAvailable,
+		)
 	} else if isDone {
 		r.setStatus(
 			sds,
@@ -3,6 +3,7 @@ package controllers
 import (
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
@@ -26,3 +27,36 @@ func newCondition(conditionType, message, status, reason string) deploymentv1.Co
 		LastTransitionTime: metav1.NewTime(time.Now()),
 	}
 }
+
+// getQOSClass computes the QoS class of pods the same way as kubelet, the init containers are taken into account
+// like the containers, and only cpu and memory are
+func getQOSClass(podSpec *corev1.PodSpec) corev1.PodQOSClass {
+	containers := append(append([]corev1.Container{}, podSpec.Containers...), podSpec.InitContainers...)
+	isGuaranteed := true
+	hasResources := false
+	for i := range containers {
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			request, hasRequest := containers[i].Resources.Requests[name]
+			limit, hasLimit := containers[i].Resources.Limits[name]
+			if (hasRequest && !request.IsZero()) || (hasLimit && !limit.IsZero()) {
+				hasResources = true
+			}
+			if !hasLimit || limit.IsZero() {
+				isGuaranteed = false
+				continue
+			}
+			// An empty request is defaulted to the limit
+			if hasRequest && request.Cmp(limit) != 0 {
+				isGuaranteed = false
+			}
+		}
+	}
+
+	if !hasResources {
+		return corev1.PodQOSBestEffort
+	}
+	if isGuaranteed {
+		return corev1.PodQOSGuaranteed
+	}
+	return corev1.PodQOSBurstable
+}
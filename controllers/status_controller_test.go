@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func Test_getQOSClass(t *testing.T) {
+	guaranteed := corev1.Container{
+		Name: "main",
+		Resources: corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("128Mi"),
+			},
+		},
+	}
+	bestEffort := corev1.Container{Name: "init"}
+
+	tests := []struct {
+		name    string
+		podSpec *corev1.PodSpec
+		want    corev1.PodQOSClass
+	}{
+		{
+			name:    "Test case containers with limits",
+			podSpec: &corev1.PodSpec{Containers: []corev1.Container{guaranteed}},
+			want:    corev1.PodQOSGuaranteed,
+		},
+		{
+			name:    "Test case init container with limits",
+			podSpec: &corev1.PodSpec{Containers: []corev1.Container{guaranteed}, InitContainers: []corev1.Container{guaranteed}},
+			want:    corev1.PodQOSGuaranteed,
+		},
+		{
+			name:    "Test case init container without limits",
+			podSpec: &corev1.PodSpec{Containers: []corev1.Container{guaranteed}, InitContainers: []corev1.Container{bestEffort}},
+			want:    corev1.PodQOSBurstable,
+		},
+		{
+			name:    "Test case init container with limits and containers without",
+			podSpec: &corev1.PodSpec{Containers: []corev1.Container{bestEffort}, InitContainers: []corev1.Container{guaranteed}},
+			want:    corev1.PodQOSBurstable,
+		},
+		{
+			name:    "Test case no resources",
+			podSpec: &corev1.PodSpec{Containers: []corev1.Container{bestEffort}, InitContainers: []corev1.Container{bestEffort}},
+			want:    corev1.PodQOSBestEffort,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getQOSClass(tt.podSpec); got != tt.want {
+				t.Errorf("getQOSClass() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
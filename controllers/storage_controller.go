@@ -0,0 +1,306 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+)
+
+// StorageFinalizer keeps the instance until its retained PersistentVolumeClaims are released
+const StorageFinalizer = "deployment.github.com/storage"
+
+// reconcileStorage creates and resizes the PersistentVolumeClaims of spec.storage, removes the ones
+// not in spec.storage any more and records their binding state in the storage condition.
+func (r *SingleDeploymentReconciler) reconcileStorage(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) {
+	if err := r.cleanupStorage(ctx, logger, sd); err != nil {
+		r.setConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeStorage,
+			sd.Name,
+			fmt.Sprintf("Removed storage cleanup failed: %s", err.Error()),
+			deploymentv1.ConditionStatusFailed,
+			deploymentv1.ConditionReasonStorageUnavailable,
+		)
+		return
+	}
+
+	if len(sd.Spec.Storage) == 0 {
+		r.deleteConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeStorage,
+		)
+		return
+	}
+
+	var failed, pending []string
+	for i := range sd.Spec.Storage {
+		name := storageClaimName(sd.Name, sd.Spec.Storage[i].Name)
+		pvc := new(corev1.PersistentVolumeClaim)
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: sd.Namespace, Name: name}, pvc); err != nil {
+			if errors.IsNotFound(err) {
+				// Its a "not found error" that is none a pvc, create it.
+				if errCreate := r.createPersistentVolumeClaim(ctx, logger, sd, &sd.Spec.Storage[i]); errCreate != nil {
+					failed = append(failed, fmt.Sprintf("PersistentVolumeClaim \"%s\" create failed: %s", name, errCreate.Error()))
+				} else {
+					pending = append(pending, fmt.Sprintf("PersistentVolumeClaim \"%s\" is creating", name))
+				}
+			} else {
+				// Its not a "not found err", throw it
+				logger.Error(err, "Get PersistentVolumeClaim failed")
+				failed = append(failed, fmt.Sprintf("PersistentVolumeClaim \"%s\" get failed: %s", name, err.Error()))
+			}
+			continue
+		}
+
+		if err := r.updatePersistentVolumeClaim(ctx, logger, sd, &sd.Spec.Storage[i], pvc); err != nil {
+			failed = append(failed, fmt.Sprintf("PersistentVolumeClaim \"%s\" update failed: %s", name, err.Error()))
+			continue
+		}
+
+		switch pvc.Status.Phase {
+		case corev1.ClaimBound:
+		case corev1.ClaimLost:
+			failed = append(failed, fmt.Sprintf("PersistentVolumeClaim \"%s\" lost its volume", name))
+		default:
+			pending = append(pending, fmt.Sprintf("PersistentVolumeClaim \"%s\" is waiting to be bound", name))
+		}
+	}
+
+	switch {
+	case len(failed) != 0:
+		r.setConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeStorage,
+			sd.Name,
+			strings.Join(failed, "; "),
+			deploymentv1.ConditionStatusFailed,
+			deploymentv1.ConditionReasonStorageUnavailable,
+		)
+	case len(pending) != 0:
+		r.setConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeStorage,
+			sd.Name,
+			strings.Join(pending, "; "),
+			deploymentv1.ConditionStatusUnKnown,
+			deploymentv1.ConditionReasonStorageUnavailable,
+		)
+	default:
+		r.setConditions(
+			&sd.Status,
+			deploymentv1.ConditionTypeStorage,
+			sd.Name,
+			fmt.Sprintf("All %d PersistentVolumeClaims are bound", len(sd.Spec.Storage)),
+			deploymentv1.ConditionStatusReady,
+			deploymentv1.ConditionReasonStorageAvailable,
+		)
+	}
+}
+
+// reconcileStorageFinalizer adds the storage finalizer when some storage is retained, and removes it
+// when none is. It returns true when the instance is updated and the reconcile should stop.
+func (r *SingleDeploymentReconciler) reconcileStorageFinalizer(ctx context.Context, sd *deploymentv1.SingleDeployment) (bool, error) {
+	retain := false
+	for i := range sd.Spec.Storage {
+		if sd.Spec.Storage[i].DeletePolicy == deploymentv1.StorageDeletePolicyRetain {
+			retain = true
+		}
+	}
+	if retain == controllerutil.ContainsFinalizer(sd, StorageFinalizer) {
+		return false, nil
+	}
+
+	sdCopy := sd.DeepCopy()
+	if retain {
+		controllerutil.AddFinalizer(sdCopy, StorageFinalizer)
+	} else {
+		controllerutil.RemoveFinalizer(sdCopy, StorageFinalizer)
+	}
+
+	return true, r.Client.Update(ctx, sdCopy)
+}
+
+// finalizeStorage releases the retained PersistentVolumeClaims of a deleted instance,
+// so the garbage collector does not delete them with it.
+func (r *SingleDeploymentReconciler) finalizeStorage(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	if !controllerutil.ContainsFinalizer(sd, StorageFinalizer) {
+		return nil
+	}
+
+	pvcs, err := r.listPersistentVolumeClaims(ctx, sd)
+	if err != nil {
+		return err
+	}
+	for i := range pvcs {
+		if pvcs[i].Annotations[StorageDeletePolicyAnnotation] != deploymentv1.StorageDeletePolicyRetain {
+			continue
+		}
+		if err := r.orphanPersistentVolumeClaim(ctx, logger, sd, &pvcs[i]); err != nil {
+			return err
+		}
+	}
+
+	sdCopy := sd.DeepCopy()
+	controllerutil.RemoveFinalizer(sdCopy, StorageFinalizer)
+	return r.Client.Update(ctx, sdCopy)
+}
+
+// cleanupStorage deletes or releases the PersistentVolumeClaims whose storage is removed from spec
+func (r *SingleDeploymentReconciler) cleanupStorage(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment) error {
+	pvcs, err := r.listPersistentVolumeClaims(ctx, sd)
+	if err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	for i := range sd.Spec.Storage {
+		names[storageClaimName(sd.Name, sd.Spec.Storage[i].Name)] = true
+	}
+	for i := range pvcs {
+		if names[pvcs[i].Name] {
+			continue
+		}
+		if pvcs[i].Annotations[StorageDeletePolicyAnnotation] == deploymentv1.StorageDeletePolicyRetain {
+			err = r.orphanPersistentVolumeClaim(ctx, logger, sd, &pvcs[i])
+		} else {
+			err = r.deletePersistentVolumeClaim(ctx, logger, &pvcs[i])
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listPersistentVolumeClaims lists the PersistentVolumeClaims controlled by the instance
+func (r *SingleDeploymentReconciler) listPersistentVolumeClaims(ctx context.Context, sd *deploymentv1.SingleDeployment) ([]corev1.PersistentVolumeClaim, error) {
+	list := new(corev1.PersistentVolumeClaimList)
+	if err := r.Client.List(ctx, list,
+		client.InNamespace(sd.Namespace),
+		client.MatchingLabels{"app": sd.Name},
+	); err != nil {
+		return nil, err
+	}
+
+	pvcs := make([]corev1.PersistentVolumeClaim, 0, len(list.Items))
+	for i := range list.Items {
+		if metav1.IsControlledBy(&list.Items[i], sd) {
+			pvcs = append(pvcs, list.Items[i])
+		}
+	}
+
+	return pvcs, nil
+}
+
+func (r *SingleDeploymentReconciler) generatePersistentVolumeClaim(sd *deploymentv1.SingleDeployment, storage *deploymentv1.Storage) (*corev1.PersistentVolumeClaim, error) {
+	pvc := newPersistentVolumeClaim(sd, storage)
+	if err := controllerutil.SetControllerReference(sd, pvc, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	return pvc, nil
+}
+
+func (r *SingleDeploymentReconciler) createPersistentVolumeClaim(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, storage *deploymentv1.Storage) error {
+	pvc, err := r.generatePersistentVolumeClaim(sd, storage)
+	if err != nil {
+		return err
+	}
+	if err := r.Client.Create(ctx, pvc); err != nil {
+		logger.Error(err, "Create New PersistentVolumeClaim failed")
+		return err
+	}
+
+	return nil
+}
+
+// updatePersistentVolumeClaim grows the volume and syncs the delete policy, the other fields of
+// a PersistentVolumeClaim can not be changed after it is created
+func (r *SingleDeploymentReconciler) updatePersistentVolumeClaim(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, storage *deploymentv1.Storage, pvc *corev1.PersistentVolumeClaim) error {
+	pvcCopy := pvc.DeepCopy()
+	changed := false
+	if !metav1.IsControlledBy(pvc, sd) {
+		if !retainedPersistentVolumeClaim(sd, pvc) {
+			return fmt.Errorf("it exists and is not controlled by SingleDeployment \"%s\"", sd.Name)
+		}
+		// The PersistentVolumeClaim is kept by the Retain policy of an instance with the same name, adopt it
+		if err := controllerutil.SetControllerReference(sd, pvcCopy, r.Scheme); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	desired := newPersistentVolumeClaim(sd, storage)
+	if current := pvcCopy.Spec.Resources.Requests[corev1.ResourceStorage]; current.Cmp(storage.Size) < 0 {
+		if pvcCopy.Spec.Resources.Requests == nil {
+			pvcCopy.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		pvcCopy.Spec.Resources.Requests[corev1.ResourceStorage] = storage.Size
+		changed = true
+	}
+	if pvcCopy.Annotations[StorageDeletePolicyAnnotation] != desired.Annotations[StorageDeletePolicyAnnotation] {
+		if desired.Annotations[StorageDeletePolicyAnnotation] == "" {
+			delete(pvcCopy.Annotations, StorageDeletePolicyAnnotation)
+		} else {
+			if pvcCopy.Annotations == nil {
+				pvcCopy.Annotations = map[string]string{}
+			}
+			pvcCopy.Annotations[StorageDeletePolicyAnnotation] = desired.Annotations[StorageDeletePolicyAnnotation]
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	if err := r.Client.Update(ctx, pvcCopy); err != nil {
+		logger.Error(err, "Update PersistentVolumeClaim failed")
+		return err
+	}
+
+	return nil
+}
+
+func (r *SingleDeploymentReconciler) deletePersistentVolumeClaim(ctx context.Context, logger logr.Logger, pvc *corev1.PersistentVolumeClaim) error {
+	if err := r.Client.Delete(ctx, pvc); err != nil && !errors.IsNotFound(err) {
+		logger.Error(err, "Delete PersistentVolumeClaim failed")
+		return err
+	}
+	return nil
+}
+
+// retainedPersistentVolumeClaim returns true when the PersistentVolumeClaim has no controller and is kept by the
+// Retain policy of an instance with the same name
+func retainedPersistentVolumeClaim(sd *deploymentv1.SingleDeployment, pvc *corev1.PersistentVolumeClaim) bool {
+	return metav1.GetControllerOf(pvc) == nil &&
+		pvc.Annotations[StorageDeletePolicyAnnotation] == deploymentv1.StorageDeletePolicyRetain &&
+		pvc.Labels["app"] == sd.Name
+}
+
+// orphanPersistentVolumeClaim removes the owner reference of instance, the PersistentVolumeClaim is kept after that
+func (r *SingleDeploymentReconciler) orphanPersistentVolumeClaim(ctx context.Context, logger logr.Logger, sd *deploymentv1.SingleDeployment, pvc *corev1.PersistentVolumeClaim) error {
+	pvcCopy := pvc.DeepCopy()
+	pvcCopy.OwnerReferences = nil
+	for _, ref := range pvc.OwnerReferences {
+		if ref.UID != sd.UID {
+			pvcCopy.OwnerReferences = append(pvcCopy.OwnerReferences, ref)
+		}
+	}
+
+	if err := r.Client.Update(ctx, pvcCopy); err != nil && !errors.IsNotFound(err) {
+		logger.Error(err, "Release PersistentVolumeClaim failed")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func Test_updatePersistentVolumeClaim(t *testing.T) {
+	sd := makeSingleDeployment("deployment_v1_singledeployment_rc_nodeport_storage.yaml")
+	sd.UID = types.UID("singledeployment-sample-nodeport")
+	storage := &sd.Spec.Storage[0]
+
+	// retained is the PersistentVolumeClaim kept by the Retain policy of a deleted instance with the same name
+	retained := newPersistentVolumeClaim(sd, storage)
+	other := retained.DeepCopy()
+	other.Labels = map[string]string{"app": "other"}
+	notRetained := retained.DeepCopy()
+	notRetained.Annotations = nil
+	isController := true
+	controlled := retained.DeepCopy()
+	controlled.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: deploymentv1.GroupVersion.String(),
+		Kind:       "SingleDeployment",
+		Name:       sd.Name,
+		UID:        types.UID("deleted"),
+		Controller: &isController,
+	}}
+
+	tests := []struct {
+		name        string
+		existing    *corev1.PersistentVolumeClaim
+		wantAdopted bool
+		wantErr     bool
+	}{
+		{
+			name:        "Test case retained persistentvolumeclaim is adopted",
+			existing:    retained,
+			wantAdopted: true,
+			wantErr:     false,
+		},
+		{
+			name:        "Test case persistentvolumeclaim of other app is not adopted",
+			existing:    other,
+			wantAdopted: false,
+			wantErr:     true,
+		},
+		{
+			name:        "Test case persistentvolumeclaim not retained is not adopted",
+			existing:    notRetained,
+			wantAdopted: false,
+			wantErr:     true,
+		},
+		{
+			name:        "Test case persistentvolumeclaim controlled by others is not adopted",
+			existing:    controlled,
+			wantAdopted: false,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReconciler(tt.existing.DeepCopy())
+			pvc := new(corev1.PersistentVolumeClaim)
+			if err := r.Client.Get(context.Background(), client.ObjectKeyFromObject(tt.existing), pvc); err != nil {
+				t.Fatal(err)
+			}
+			err := r.updatePersistentVolumeClaim(context.Background(), log.Log, sd, storage, pvc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("updatePersistentVolumeClaim() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			got := new(corev1.PersistentVolumeClaim)
+			if err := r.Client.Get(context.Background(), client.ObjectKeyFromObject(tt.existing), got); err != nil {
+				t.Fatal(err)
+			}
+			if adopted := metav1.IsControlledBy(got, sd); adopted != tt.wantAdopted {
+				t.Errorf("updatePersistentVolumeClaim() adopted = %v, want %v", adopted, tt.wantAdopted)
+			}
+		})
+	}
+}
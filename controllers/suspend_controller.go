@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"reflect"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
+)
+
+// reconcileSuspend records the replicas of deployment when spec.suspend is set, they are removed after the
+// deployment is resumed
+func (r *SingleDeploymentReconciler) reconcileSuspend(sd *deploymentv1.SingleDeployment, deploy *appsv1.Deployment) {
+	if deploy.Spec.Replicas == nil || suspended(deploy) {
+		return
+	}
+	if !sd.Spec.Suspend {
+		r.setSuspendedReplicas(&sd.Status, nil)
+		return
+	}
+
+	replicas := *deploy.Spec.Replicas
+	r.setSuspendedReplicas(&sd.Status, &replicas)
+}
+
+func (r *SingleDeploymentReconciler) setSuspendedReplicas(
+	sdStatus *deploymentv1.SingleDeploymentStatus,
+	replicas *int32,
+) {
+	if reflect.DeepEqual(sdStatus.SuspendedReplicas, replicas) {
+		return
+	}
+	sdStatus.SuspendedReplicas = replicas
+	sdStatus.ObservedGeneration++
+}
+
+// suspended returns true when the deployment is scaled to 0
+func suspended(deploy *appsv1.Deployment) bool {
+	return deploy.Spec.Replicas != nil && *deploy.Spec.Replicas == 0
+}
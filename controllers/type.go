@@ -1,6 +1,10 @@
 package controllers
 
 const (
-	ServiceNodePort = "nodeport"
-	ServiceIngress  = "ingress"
+	ServiceNodePort     = "nodeport"
+	ServiceIngress      = "ingress"
+	ServiceLoadBalancer = "loadbalancer"
+	ServiceGateway      = "gateway"
+	ServiceInternal     = "internal"
+	ServiceNone         = "none"
 )
@@ -7,6 +7,7 @@ require (
 	github.com/go-resty/resty/v2 v2.7.0
 	github.com/onsi/ginkgo v1.16.5
 	github.com/onsi/gomega v1.20.0
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/spf13/viper v1.7.0
 	go.uber.org/zap v1.19.1
 	k8s.io/api v0.24.0
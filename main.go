@@ -30,6 +30,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
 
 	deploymentv1 "github.com/Madongming/move-clouds-deployment/api/v1"
 	"github.com/Madongming/move-clouds-deployment/controllers"
@@ -52,11 +53,14 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var defaultsConfig string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&defaultsConfig, "defaults-config", "",
+		"The file of defaults the SingleDeployment defaulting webhook fills spec with. Empty means no defaults.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -65,6 +69,20 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if defaultsConfig != "" {
+		content, err := os.ReadFile(defaultsConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to read defaults config", "file", defaultsConfig)
+			os.Exit(1)
+		}
+		config := deploymentv1.DefaultsConfig{}
+		if err := yaml.UnmarshalStrict(content, &config); err != nil {
+			setupLog.Error(err, "unable to parse defaults config", "file", defaultsConfig)
+			os.Exit(1)
+		}
+		deploymentv1.SetDefaultsConfig(config)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -72,6 +90,7 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "cc383556.github.com",
+		NewCache:               controllers.NewCache,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly